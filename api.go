@@ -48,6 +48,29 @@ func LoggingMiddleware(r *mux.Router) mux.MiddlewareFunc {
 	}
 }
 
+// Number of elements returned by list endpoints
+const (
+	defaultAPILimit = 100
+	maxAPILimit     = 1000
+)
+
+// parseLimit returns the value of the "limit" query parameter, or the default value when it's not defined
+// The limit must be between 1 and max
+func parseLimit(r *http.Request, defaultLimit int, max int) (int, error) {
+	limitFilter := r.URL.Query().Get("limit")
+	if limitFilter == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(limitFilter)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse limit query to integer: %s", err)
+	}
+	if limit < 1 || limit > max {
+		return 0, fmt.Errorf("limit query must be between 1 and %d, got %d", max, limit)
+	}
+	return limit, nil
+}
+
 // handle health checks
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "OK")
@@ -146,17 +169,209 @@ func (h *productHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// pricesHandler to expose price history of a product over HTTP with a database connection
+type pricesHandler struct {
+	db *gorm.DB
+}
+
+// ServeHTTP to implement the handle interface for serving price history of a product
+func (h *pricesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var product Product
+	trx := h.db.First(&product, id)
+	if trx.Error == gorm.ErrRecordNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if trx.Error != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var prices []PriceHistory
+	trx = h.db.Where(PriceHistory{ProductID: product.ID}).Order("created_at asc").Find(&prices)
+	if trx.Error == nil {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(prices)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// eventsHandler to expose availability events over HTTP with a database connection
+type eventsHandler struct {
+	db *gorm.DB
+}
+
+// ServeHTTP to implement the handle interface for serving availability events
+// Events can be filtered by product with the "id" route variable and by "shop_id" with a query parameter
+// The "limit" query parameter controls the number of returned events (see parseLimit)
+func (h *eventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+
+	limit, err := parseLimit(r, defaultAPILimit, maxAPILimit)
+	if err != nil {
+		log.Warnf("%s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	trx := h.db.Preload("Product.Shop").Order("created_at desc").Limit(limit)
+	if id, ok := vars["id"]; ok {
+		trx = trx.Where("product_id = ?", id)
+	}
+	if shopID := r.URL.Query().Get("shop_id"); shopID != "" {
+		trx = trx.Where("shop_id = ?", shopID)
+	}
+
+	var events []AvailabilityEvent
+	if trx = trx.Find(&events); trx.Error == nil {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(events)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// restockStatsHandler to expose restock statistics over HTTP with a database connection
+type restockStatsHandler struct {
+	db    *gorm.DB
+	group RestockGroupFunc
+}
+
+// ServeHTTP to implement the handle interface for serving restock statistics
+// Period can be defined in number of days with the "days" query parameter (30 by default)
+func (h *restockStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	days := 30
+	if daysFilter := r.URL.Query().Get("days"); daysFilter != "" {
+		value, err := strconv.Atoi(daysFilter)
+		if err != nil {
+			log.Warnf("cannot parse days query to integer: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		days = value
+	}
+
+	since := time.Now().Add(-time.Hour * 24 * time.Duration(days))
+	events, err := FindAvailabilityEvents(h.db, since)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	stats := ComputeRestockStats(events, h.group, time.Now())
+	if stats == nil {
+		stats = []RestockStats{}
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// shopRunsHandler to expose executions of parsers over HTTP with a database connection
+type shopRunsHandler struct {
+	db *gorm.DB
+}
+
+// ServeHTTP to implement the handle interface for serving shop runs
+// Runs can be filtered by shop with the "id" route variable and by "status" with a query parameter
+func (h *shopRunsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+
+	limit, err := parseLimit(r, defaultAPILimit, maxAPILimit)
+	if err != nil {
+		log.Warnf("%s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	trx := h.db.Preload("Shop").Order("started_at desc").Limit(limit)
+	if id, ok := vars["id"]; ok {
+		trx = trx.Where("shop_id = ?", id)
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		trx = trx.Where(ShopRun{Status: status})
+	}
+
+	var runs []ShopRun
+	if trx = trx.Find(&runs); trx.Error == nil {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(runs)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// deliveriesHandler to expose deliveries of the notification outbox over HTTP with a database connection
+type deliveriesHandler struct {
+	db *gorm.DB
+}
+
+// ServeHTTP to implement the handle interface for serving deliveries
+// Pending and failed deliveries are returned by default, they can be filtered by "status" and "notifier" query parameters
+func (h *deliveriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, err := parseLimit(r, defaultAPILimit, maxAPILimit)
+	if err != nil {
+		log.Warnf("%s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	trx := h.db.Preload("OutboxEvent.Product.Shop").Order("id desc").Limit(limit)
+	if status := r.URL.Query().Get("status"); status != "" {
+		trx = trx.Where(OutboxDelivery{Status: status})
+	} else {
+		trx = trx.Where("status IN ?", []string{DeliveryPending, DeliveryFailed})
+	}
+	if notifier := r.URL.Query().Get("notifier"); notifier != "" {
+		trx = trx.Where(OutboxDelivery{Notifier: notifier})
+	}
+
+	var deliveries []OutboxDelivery
+	if trx = trx.Find(&deliveries); trx.Error == nil {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(deliveries)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
 // StartAPI to handle HTTP requests
-func StartAPI(db *gorm.DB, config APIConfig) error {
+func StartAPI(db *gorm.DB, config APIConfig, reportConfig ReportConfig) error {
+	groupByModel, err := NewGroupByModel(reportConfig.Models)
+	if err != nil {
+		return err
+	}
+
 	router := mux.NewRouter().StrictSlash(true)
 
 	router.Path("/health").HandlerFunc(handleHealth)
 
 	router.Path("/shops").Handler(&shopsHandler{db: db})
 	router.Path("/shops/{id:[0-9]+}").Handler(&shopHandler{db: db})
+	router.Path("/shops/{id:[0-9]+}/runs").Handler(&shopRunsHandler{db: db})
+
+	router.Path("/runs").Handler(&shopRunsHandler{db: db})
 
 	router.Path("/products").Handler(&productsHandler{db: db})
 	router.Path("/products/{id:[0-9]+}").Handler(&productHandler{db: db})
+	router.Path("/products/{id:[0-9]+}/prices").Handler(&pricesHandler{db: db})
+	router.Path("/products/{id:[0-9]+}/events").Handler(&eventsHandler{db: db})
+
+	router.Path("/events").Handler(&eventsHandler{db: db})
+	router.Path("/restocks/shops").Handler(&restockStatsHandler{db: db, group: GroupByShop})
+	router.Path("/restocks/models").Handler(&restockStatsHandler{db: db, group: groupByModel})
+
+	router.Path("/deliveries").Handler(&deliveriesHandler{db: db})
 
 	// register middlewares
 	router.Use(LoggingMiddleware(router))
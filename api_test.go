@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected int
+		err      bool
+	}{
+		{"", 100, false},
+		{"?limit=10", 10, false},
+		{"?limit=1000", 1000, false},
+		{"?limit=1001", 0, true},
+		{"?limit=0", 0, true},
+		{"?limit=-1", 0, true},
+		{"?limit=ten", 0, true},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestParseLimit#%d", i), func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/events"+tc.query, nil)
+			got, err := parseLimit(r, defaultAPILimit, maxAPILimit)
+			if (err != nil) != tc.err || got != tc.expected {
+				t.Errorf("for '%s', got %d and error %v, want %d and error %t", tc.query, got, err, tc.expected, tc.err)
+			} else {
+				t.Logf("for '%s', got %d and error %v", tc.query, got, err)
+			}
+		})
+	}
+}
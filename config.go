@@ -8,17 +8,44 @@ import (
 
 // Config to store JSON configuration
 type Config struct {
-	DatabaseConfig `json:"database"`
-	TwitterConfig  `json:"twitter"`
-	TelegramConfig `json:"telegram"`
-	APIConfig      `json:"api"`
-	AmazonConfig   `json:"amazon"`
-	NvidiaFEConfig `json:"nvidia_fe"`
-	URLs           []string     `json:"urls"`
-	IncludeRegex   string       `json:"include_regex"`
-	ExcludeRegex   string       `json:"exclude_regex"`
-	PriceRanges    []PriceRange `json:"price_ranges"`
-	BrowserAddress string       `json:"browser_address"`
+	DatabaseConfig  `json:"database"`
+	TwitterConfig   `json:"twitter"`
+	TelegramConfig  `json:"telegram"`
+	DiscordConfig   `json:"discord"`
+	SlackConfig     `json:"slack"`
+	Webhooks        []WebhookConfig `json:"webhooks"`
+	EmailConfig     `json:"email"`
+	MatrixConfig    `json:"matrix"`
+	PushConfig      `json:"push"`
+	MQTTConfig      `json:"mqtt"`
+	MastodonConfig  `json:"mastodon"`
+	APIConfig       `json:"api"`
+	AmazonConfig    `json:"amazon"`
+	NvidiaFEConfig  `json:"nvidia_fe"`
+	SchemaOrgConfig `json:"schema_org"`
+	Selectors       []SelectorConfig    `json:"selectors"`
+	ProductPages    []ProductPageConfig `json:"product_pages"`
+	DaemonConfig    `json:"daemon"`
+	OutboxConfig    `json:"outbox"`
+	ReportConfig    `json:"report"`
+	URLs            []string     `json:"urls"`
+	IncludeRegex    string       `json:"include_regex"`
+	ExcludeRegex    string       `json:"exclude_regex"`
+	PriceRanges     []PriceRange `json:"price_ranges"`
+	PriceDropConfig `json:"price_drop"`
+	BrowserAddress  string            `json:"browser_address"`
+	QueriesDir      string            `json:"queries_directory"`
+	Queries         map[string]string `json:"queries"`
+	Language        string            `json:"language"`
+}
+
+// TemplatesConfig to store message templates of a notifier
+type TemplatesConfig struct {
+	Language     string `json:"language"`
+	Available    string `json:"available"`
+	NotAvailable string `json:"not_available"`
+	PriceDrop    string `json:"price_drop"`
+	Subject      string `json:"subject"`
 }
 
 // DatabaseConfig to store database configuration
@@ -33,17 +60,117 @@ type TwitterConfig struct {
 	ConsumerSecret    string              `json:"consumer_secret"`
 	AccessToken       string              `json:"access_token"`
 	AccessTokenSecret string              `json:"access_token_secret"`
+	APIURL            string              `json:"api_url"`
 	Hashtags          []map[string]string `json:"hashtags"`
 	EnableReplies     bool                `json:"enable_replies"`
 	Retention         int                 `json:"retention"`
+	Templates         TemplatesConfig     `json:"templates"`
 }
 
 // TelegramConfig to store Telegram API key
 type TelegramConfig struct {
-	Token         string `json:"token"`
-	ChatID        int64  `json:"chat_id"`
-	ChannelName   string `json:"channel_name"`
-	EnableReplies bool   `json:"enable_replies"`
+	Token             string          `json:"token"`
+	ChatID            int64           `json:"chat_id"`
+	ChannelName       string          `json:"channel_name"`
+	EnableReplies     bool            `json:"enable_replies"`
+	Cooldown          int             `json:"cooldown"`
+	MessagesPerMinute int             `json:"messages_per_minute"`
+	ReuseWindow       int             `json:"reuse_window"`
+	EnableBot         bool            `json:"enable_bot"`
+	AllowedChats      []int64         `json:"allowed_chats"`
+	MaxSubscriptions  int             `json:"max_subscriptions"`
+	Templates         TemplatesConfig `json:"templates"`
+}
+
+// DiscordConfig to store Discord webhook
+type DiscordConfig struct {
+	WebhookURL    string          `json:"webhook_url"`
+	Username      string          `json:"username"`
+	AvatarURL     string          `json:"avatar_url"`
+	EnableReplies bool            `json:"enable_replies"`
+	EditMessages  bool            `json:"edit_messages"`
+	Templates     TemplatesConfig `json:"templates"`
+}
+
+// SlackConfig to store Slack API token or webhook
+type SlackConfig struct {
+	Token         string          `json:"token"`
+	Channel       string          `json:"channel"`
+	APIURL        string          `json:"api_url"`
+	WebhookURL    string          `json:"webhook_url"`
+	EnableReplies bool            `json:"enable_replies"`
+	Templates     TemplatesConfig `json:"templates"`
+}
+
+// WebhookConfig to store how to send events to an HTTP endpoint
+type WebhookConfig struct {
+	URL                  string            `json:"url"`
+	Method               string            `json:"method"`
+	Format               string            `json:"format"`
+	AvailableTemplate    string            `json:"available_template"`
+	NotAvailableTemplate string            `json:"not_available_template"`
+	Headers              map[string]string `json:"headers"`
+	Secret               string            `json:"secret"`
+	SignatureHeader      string            `json:"signature_header"`
+	Timeout              int               `json:"timeout"`
+}
+
+// EmailConfig to store SMTP server settings and recipients
+type EmailConfig struct {
+	Host          string          `json:"host"`
+	Port          int             `json:"port"`
+	Username      string          `json:"username"`
+	Password      string          `json:"password"`
+	TLS           string          `json:"tls"`
+	From          string          `json:"from"`
+	To            []string        `json:"to"`
+	EnableReplies bool            `json:"enable_replies"`
+	Templates     TemplatesConfig `json:"templates"`
+}
+
+// MatrixConfig to store Matrix homeserver, credentials and room
+type MatrixConfig struct {
+	HomeserverURL string          `json:"homeserver_url"`
+	AccessToken   string          `json:"access_token"`
+	RoomID        string          `json:"room_id"`
+	EnableReplies bool            `json:"enable_replies"`
+	Templates     TemplatesConfig `json:"templates"`
+}
+
+// PushConfig to store ntfy or Gotify server settings
+type PushConfig struct {
+	Service    string              `json:"service"`
+	URL        string              `json:"url"`
+	Topic      string              `json:"topic"`
+	Token      string              `json:"token"`
+	Priorities map[string]int      `json:"priorities"`
+	Tags       []map[string]string `json:"tags"`
+	Templates  TemplatesConfig     `json:"templates"`
+}
+
+// MQTTConfig to store MQTT broker settings
+type MQTTConfig struct {
+	Broker             string `json:"broker"`
+	ClientID           string `json:"client_id"`
+	Username           string `json:"username"`
+	Password           string `json:"password"`
+	QoS                byte   `json:"qos"`
+	TopicPrefix        string `json:"topic_prefix"`
+	CAFile             string `json:"ca_file"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	Timeout            int    `json:"timeout"`
+}
+
+// MastodonConfig to store Mastodon instance and access token
+type MastodonConfig struct {
+	InstanceURL   string              `json:"instance_url"`
+	AccessToken   string              `json:"access_token"`
+	Visibility    string              `json:"visibility"`
+	MaxCharacters int                 `json:"max_characters"`
+	Hashtags      []map[string]string `json:"hashtags"`
+	EnableReplies bool                `json:"enable_replies"`
+	Retention     int                 `json:"retention"`
+	Templates     TemplatesConfig     `json:"templates"`
 }
 
 // APIConfig to store HTTP API configuration
@@ -75,6 +202,74 @@ type NvidiaFEConfig struct {
 	Timeout   int      `json:"timeout"`
 }
 
+// SchemaOrgConfig to store web pages parsed with schema.org metadata
+type SchemaOrgConfig struct {
+	URLs      []string `json:"urls"`
+	MaxPages  int      `json:"max_pages"`
+	UserAgent string   `json:"user_agent"`
+	Timeout   int      `json:"timeout"`
+}
+
+// SelectorConfig to store CSS selectors to parse a shop
+type SelectorConfig struct {
+	URL              string `json:"url"`
+	Driver           string `json:"driver"`
+	Item             string `json:"item"`
+	Name             string `json:"name"`
+	Link             string `json:"link"`
+	Price            string `json:"price"`
+	PriceRegex       string `json:"price_regex"`
+	Currency         string `json:"currency"`
+	Availability     string `json:"availability"`
+	AvailableRegex   string `json:"available_regex"`
+	UnavailableRegex string `json:"unavailable_regex"`
+	Pagination       string `json:"pagination"`
+	MaxPages         int    `json:"max_pages"`
+	UserAgent        string `json:"user_agent"`
+	Timeout          int    `json:"timeout"`
+}
+
+// ProductPageConfig to store how to parse the page of a single product
+// Selectors are optional when the page embeds schema.org metadata
+type ProductPageConfig struct {
+	URL              string `json:"url"`
+	Driver           string `json:"driver"`
+	Name             string `json:"name"`
+	Price            string `json:"price"`
+	PriceRegex       string `json:"price_regex"`
+	Currency         string `json:"currency"`
+	Availability     string `json:"availability"`
+	AvailableRegex   string `json:"available_regex"`
+	UnavailableRegex string `json:"unavailable_regex"`
+	UserAgent        string `json:"user_agent"`
+	Timeout          int    `json:"timeout"`
+}
+
+// OutboxConfig to store how notifications are retried
+type OutboxConfig struct {
+	MaxAttempts int `json:"max_attempts"`
+	Backoff     int `json:"backoff"`
+	Interval    int `json:"interval"`
+}
+
+// DaemonConfig to store schedules used by the daemon mode
+type DaemonConfig struct {
+	Interval  int            `json:"interval"`
+	Jitter    int            `json:"jitter"`
+	Intervals map[string]int `json:"intervals"`
+}
+
+// ReportConfig to store how restock statistics are grouped
+type ReportConfig struct {
+	Models []string `json:"models"`
+}
+
+// PriceDropConfig to store thresholds of price drop notifications
+type PriceDropConfig struct {
+	Amount     float64 `json:"amount"`
+	Percentage float64 `json:"percentage"`
+}
+
 // PriceRange to store rules to filter products with price outside of the range
 type PriceRange struct {
 	Model    string  `json:"model"`
@@ -104,6 +299,15 @@ func (c *Config) Read(file string) error {
 	if err != nil {
 		return err
 	}
+
+	// use the global language when notifiers don't define one
+	if c.Language != "" {
+		for _, templates := range []*TemplatesConfig{&c.TwitterConfig.Templates, &c.TelegramConfig.Templates, &c.DiscordConfig.Templates, &c.SlackConfig.Templates, &c.EmailConfig.Templates, &c.MatrixConfig.Templates, &c.PushConfig.Templates, &c.MastodonConfig.Templates} {
+			if templates.Language == "" {
+				templates.Language = c.Language
+			}
+		}
+	}
 	return nil
 }
 
@@ -112,9 +316,54 @@ func (c *Config) HasTwitter() bool {
 	return (c.TwitterConfig.AccessToken != "" && c.TwitterConfig.AccessTokenSecret != "" && c.TwitterConfig.ConsumerKey != "" && c.TwitterConfig.ConsumerSecret != "")
 }
 
+// HasPriceDrop returns true when price drop notifications have been configured
+func (c *Config) HasPriceDrop() bool {
+	return c.PriceDropConfig.Amount > 0 || c.PriceDropConfig.Percentage > 0
+}
+
 // HasTelegram returns true when Telegram has been configured
 func (c *Config) HasTelegram() bool {
-	return c.TelegramConfig.Token != "" && (c.TelegramConfig.ChatID != 0 || c.TelegramConfig.ChannelName != "")
+	return c.TelegramConfig.Token != "" && (c.TelegramConfig.ChatID != 0 || c.TelegramConfig.ChannelName != "" || c.TelegramConfig.EnableBot)
+}
+
+// HasDiscord returns true when Discord has been configured
+func (c *Config) HasDiscord() bool {
+	return c.DiscordConfig.WebhookURL != ""
+}
+
+// HasSlack returns true when Slack has been configured
+func (c *Config) HasSlack() bool {
+	return (c.SlackConfig.Token != "" && c.SlackConfig.Channel != "") || c.SlackConfig.WebhookURL != ""
+}
+
+// HasWebhooks returns true when webhooks have been configured
+func (c *Config) HasWebhooks() bool {
+	return len(c.Webhooks) > 0
+}
+
+// HasEmail returns true when email has been configured
+func (c *Config) HasEmail() bool {
+	return c.EmailConfig.Host != "" && c.EmailConfig.From != "" && len(c.EmailConfig.To) > 0
+}
+
+// HasMatrix returns true when Matrix has been configured
+func (c *Config) HasMatrix() bool {
+	return c.MatrixConfig.HomeserverURL != "" && c.MatrixConfig.AccessToken != "" && c.MatrixConfig.RoomID != ""
+}
+
+// HasPush returns true when push notifications have been configured
+func (c *Config) HasPush() bool {
+	return c.PushConfig.Topic != "" || (c.PushConfig.URL != "" && c.PushConfig.Token != "")
+}
+
+// HasMQTT returns true when MQTT has been configured
+func (c *Config) HasMQTT() bool {
+	return c.MQTTConfig.Broker != ""
+}
+
+// HasMastodon returns true when Mastodon has been configured
+func (c *Config) HasMastodon() bool {
+	return c.MastodonConfig.InstanceURL != "" && c.MastodonConfig.AccessToken != ""
 }
 
 // HasURLs returns true when list of URLS has been configured
@@ -145,3 +394,18 @@ func (c *Config) HasAmazon() bool {
 	}
 	return hasKeys && hasSearches && hasMarketplaces
 }
+
+// HasSchemaOrg returns true when web pages with schema.org metadata have been configured
+func (c *Config) HasSchemaOrg() bool {
+	return len(c.SchemaOrgConfig.URLs) > 0
+}
+
+// HasSelectors returns true when shops parsed with CSS selectors have been configured
+func (c *Config) HasSelectors() bool {
+	return len(c.Selectors) > 0
+}
+
+// HasProductPages returns true when pages of single products have been configured
+func (c *Config) HasProductPages() bool {
+	return len(c.ProductPages) > 0
+}
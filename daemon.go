@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultDaemonInterval to wait between two executions of a parser when no interval is configured
+const DefaultDaemonInterval = 5 * time.Minute
+
+// Daemon to run parsers periodically, each one with its own schedule
+type Daemon struct {
+	parsers   []Parser
+	pool      *WorkerPool
+	interval  time.Duration
+	jitter    time.Duration
+	intervals map[string]time.Duration
+}
+
+// NewDaemon creates a Daemon submitting parsers to a started WorkerPool
+func NewDaemon(config DaemonConfig, parsers []Parser, pool *WorkerPool) *Daemon {
+	interval := time.Duration(config.Interval) * time.Second
+	if interval <= 0 {
+		interval = DefaultDaemonInterval
+	}
+
+	intervals := make(map[string]time.Duration)
+	for shopName, seconds := range config.Intervals {
+		intervals[shopName] = time.Duration(seconds) * time.Second
+	}
+
+	return &Daemon{
+		parsers:   parsers,
+		pool:      pool,
+		interval:  interval,
+		jitter:    time.Duration(config.Jitter) * time.Second,
+		intervals: intervals,
+	}
+}
+
+// Run schedules all parsers until the context is cancelled
+// Executions in progress are not interrupted, Run returns when they are over
+func (d *Daemon) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, parser := range d.parsers {
+		wg.Add(1)
+		go func(parser Parser) {
+			defer wg.Done()
+			d.schedule(ctx, parser)
+		}(parser)
+	}
+	log.Infof("daemon started with %d parser(s)", len(d.parsers))
+	wg.Wait()
+}
+
+// schedule runs a parser at its own interval
+func (d *Daemon) schedule(ctx context.Context, parser Parser) {
+	interval := d.intervalFor(parser)
+	log.Debugf("parser %s scheduled every %s", parser, interval)
+
+	// spread first executions over the jitter window
+	timer := time.NewTimer(d.nextDelay(0))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Debugf("parser %s unscheduled", parser)
+			return
+		case <-timer.C:
+		}
+
+		// wait for a free worker then for the end of the execution
+		result, err := d.pool.Submit(ctx, parser)
+		if err != nil {
+			log.Debugf("parser %s unscheduled", parser)
+			return
+		}
+		if r := <-result; r.Err != nil {
+			log.Warnf("%s", r)
+		} else {
+			log.Infof("%s", r)
+		}
+
+		delay := d.nextDelay(interval)
+		log.Debugf("next execution of parser %s in %s", parser, delay)
+		timer.Reset(delay)
+	}
+}
+
+// intervalFor returns the interval of a parser based on its shop name, or the default interval
+func (d *Daemon) intervalFor(parser Parser) time.Duration {
+	shopName, err := parser.ShopName()
+	if err != nil {
+		return d.interval
+	}
+	if interval, ok := d.intervals[shopName]; ok && interval > 0 {
+		return interval
+	}
+	return d.interval
+}
+
+// nextDelay adds a random jitter to an interval
+func (d *Daemon) nextDelay(interval time.Duration) time.Duration {
+	if d.jitter <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int63n(int64(d.jitter)))
+}
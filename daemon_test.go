@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeParser to test components relying on the Parser interface
+type fakeParser struct {
+	shopName string
+	products []*Product
+	err      error
+	delay    time.Duration
+}
+
+func (p *fakeParser) Parse(ctx context.Context) ([]*Product, error) {
+	if p.delay > 0 {
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return p.products, p.err
+}
+
+func (p *fakeParser) String() string {
+	return fmt.Sprintf("fakeParser<%s>", p.shopName)
+}
+
+func (p *fakeParser) ShopName() (string, error) {
+	return p.shopName, nil
+}
+
+func TestDaemonIntervalFor(t *testing.T) {
+	config := DaemonConfig{
+		Interval:  60,
+		Intervals: map[string]int{"ldlc.com": 30},
+	}
+	daemon := NewDaemon(config, nil, nil)
+
+	tests := []struct {
+		shopName string
+		expected time.Duration
+	}{
+		{"ldlc.com", 30 * time.Second},     // shop with its own interval
+		{"topachat.com", 60 * time.Second}, // shop with the default interval
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestDaemonIntervalFor#%d", i), func(t *testing.T) {
+			got := daemon.intervalFor(&fakeParser{shopName: tc.shopName})
+			if got != tc.expected {
+				t.Errorf("for %s, got %s, want %s", tc.shopName, got, tc.expected)
+			} else {
+				t.Logf("for %s, got %s, want %s", tc.shopName, got, tc.expected)
+			}
+		})
+	}
+
+	// default interval when not configured
+	daemon = NewDaemon(DaemonConfig{}, nil, nil)
+	if got := daemon.intervalFor(&fakeParser{shopName: "ldlc.com"}); got != DefaultDaemonInterval {
+		t.Errorf("without configuration, got %s, want %s", got, DefaultDaemonInterval)
+	}
+}
+
+func TestDaemonNextDelay(t *testing.T) {
+	interval := time.Minute
+	jitter := 10 * time.Second
+	daemon := NewDaemon(DaemonConfig{Jitter: int(jitter.Seconds())}, nil, nil)
+
+	for i := 0; i < 100; i++ {
+		got := daemon.nextDelay(interval)
+		if got < interval || got >= interval+jitter {
+			t.Fatalf("got delay %s, want between %s and %s", got, interval, interval+jitter)
+		}
+	}
+
+	daemon = NewDaemon(DaemonConfig{}, nil, nil)
+	if got := daemon.nextDelay(interval); got != interval {
+		t.Errorf("without jitter, got %s, want %s", got, interval)
+	}
+}
@@ -4,11 +4,12 @@ go 1.16
 
 require (
 	github.com/MontFerret/ferret v0.13.0
-	github.com/dghubble/go-twitter v0.0.0-20201011215211-4b180d0cc78d
+	github.com/PuerkitoBio/goquery v1.6.0
 	github.com/dghubble/oauth1 v0.7.0
+	github.com/eclipse/paho.mqtt.golang v1.3.5
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.0.0-rc1
 	github.com/gorilla/mux v1.8.0
-	github.com/jarcoal/httpmock v1.0.8 // indirect
+	github.com/jarcoal/httpmock v1.0.8
 	github.com/sirupsen/logrus v1.8.0
 	github.com/spiegel-im-spiegel/pa-api v0.9.0
 	gorm.io/driver/mysql v1.0.5
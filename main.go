@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"math/rand"
-	"sync"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"os"
@@ -49,6 +51,8 @@ func main() {
 	logFileName := flag.String("log-file", "", "Log file name")
 	disableNotifications := flag.Bool("disable-notifications", false, "Do not send notifications")
 	workers := flag.Int("workers", 1, "Number of workers for parsing shops")
+	parseTimeout := flag.Int("parse-timeout", 0, "Maximum number of seconds to parse a shop (disabled by default)")
+	daemon := flag.Bool("daemon", false, "Keep running and parse shops periodically (see daemon configuration)")
 	pidFile := flag.String("pid-file", "", "Write process ID to this file to disable concurrent executions")
 	pidWaitTimeout := flag.Int("pid-wait-timeout", 0, "Seconds to wait before giving up when another instance is running")
 	retention := flag.Int("retention", 0, "Automatically remove products from the database with this number of days old (disabled by default)")
@@ -56,6 +60,8 @@ func main() {
 	monitor := flag.Bool("monitor", false, "Perform health check with Nagios output")
 	warningTimeout := flag.Int("monitor-warning-timeout", 300, "Raise a warning alert when the last execution time has reached this number of seconds (see -monitor)")
 	criticalTimeout := flag.Int("monitor-critical-timeout", 600, "Raise a critical alert when the last execution time has reached this number of seconds (see -monitor)")
+	report := flag.Bool("report", false, "Print restock statistics per shop and per model, then exit")
+	reportDays := flag.Int("report-days", 30, "Number of days of availability events used to print restock statistics (see -report)")
 
 	flag.Parse()
 
@@ -122,6 +128,18 @@ func main() {
 	if err := db.AutoMigrate(&Shop{}); err != nil {
 		log.Fatalf("cannot create shops table")
 	}
+	if err := db.AutoMigrate(&ShopRun{}); err != nil {
+		log.Fatalf("cannot create shop runs table")
+	}
+	if err := db.AutoMigrate(&PriceHistory{}); err != nil {
+		log.Fatalf("cannot create price history table")
+	}
+	if err := db.AutoMigrate(&AvailabilityEvent{}); err != nil {
+		log.Fatalf("cannot create availability events table")
+	}
+	if err := db.AutoMigrate(&OutboxEvent{}, &OutboxDelivery{}); err != nil {
+		log.Fatalf("cannot create outbox tables")
+	}
 
 	// delete products not updated since retention
 	if *retention != 0 {
@@ -139,6 +157,26 @@ func main() {
 				log.Printf("stale product %s (%s) removed from database", p.Name, p.URL)
 			}
 		}
+
+		// delete shop runs older than retention
+		trx = db.Where("started_at < ?", retentionDate).Delete(&ShopRun{})
+		if trx.Error != nil {
+			log.Warnf("cannot remove stale shop runs: %s", trx.Error)
+		} else if trx.RowsAffected > 0 {
+			log.Printf("%d stale shop run(s) removed from database", trx.RowsAffected)
+		}
+
+		// delete outbox events older than retention
+		staleEvents := db.Model(&OutboxEvent{}).Select("id").Where("created_at < ?", retentionDate)
+		if trx = db.Where("outbox_event_id IN (?)", staleEvents).Delete(&OutboxDelivery{}); trx.Error != nil {
+			log.Warnf("cannot remove stale deliveries: %s", trx.Error)
+		}
+		trx = db.Where("created_at < ?", retentionDate).Delete(&OutboxEvent{})
+		if trx.Error != nil {
+			log.Warnf("cannot remove stale outbox events: %s", trx.Error)
+		} else if trx.RowsAffected > 0 {
+			log.Printf("%d stale outbox event(s) removed from database", trx.RowsAffected)
+		}
 	}
 
 	// start monitoring
@@ -146,13 +184,22 @@ func main() {
 		os.Exit(Monitor(db, *warningTimeout, *criticalTimeout))
 	}
 
+	// print restock statistics
+	if *report {
+		if err := PrintRestockReport(os.Stdout, db, config.ReportConfig.Models, *reportDays); err != nil {
+			log.Fatalf("cannot print restock report: %s", err)
+		}
+		return
+	}
+
 	// start the api
 	if *api {
-		log.Fatal(StartAPI(db, config.APIConfig))
+		log.Fatal(StartAPI(db, config.APIConfig, config.ReportConfig))
 	}
 
 	// register notifiers
 	notifiers := []Notifier{}
+	var telegramBot *TelegramNotifier
 
 	if !*disableNotifications {
 		if config.HasTwitter() {
@@ -168,7 +215,74 @@ func main() {
 				log.Fatalf("cannot create telegram client: %s", err)
 			}
 			notifiers = append(notifiers, telegramNotifier)
+			if config.TelegramConfig.EnableBot {
+				telegramBot = telegramNotifier
+			}
+		}
+		if config.HasDiscord() {
+			discordNotifier, err := NewDiscordNotifier(&config.DiscordConfig, db)
+			if err != nil {
+				log.Fatalf("cannot create discord client: %s", err)
+			}
+			notifiers = append(notifiers, discordNotifier)
+		}
+		if config.HasSlack() {
+			slackNotifier, err := NewSlackNotifier(&config.SlackConfig, db)
+			if err != nil {
+				log.Fatalf("cannot create slack client: %s", err)
+			}
+			notifiers = append(notifiers, slackNotifier)
 		}
+		if config.HasWebhooks() {
+			for _, webhookConfig := range config.Webhooks {
+				webhookNotifier, err := NewWebhookNotifier(&webhookConfig)
+				if err != nil {
+					log.Fatalf("cannot create webhook notifier for %s: %s", webhookConfig.URL, err)
+				}
+				notifiers = append(notifiers, webhookNotifier)
+			}
+		}
+		if config.HasEmail() {
+			emailNotifier, err := NewEmailNotifier(&config.EmailConfig, db)
+			if err != nil {
+				log.Fatalf("cannot create email client: %s", err)
+			}
+			notifiers = append(notifiers, emailNotifier)
+		}
+		if config.HasMatrix() {
+			matrixNotifier, err := NewMatrixNotifier(&config.MatrixConfig, db)
+			if err != nil {
+				log.Fatalf("cannot create matrix client: %s", err)
+			}
+			notifiers = append(notifiers, matrixNotifier)
+		}
+		if config.HasPush() {
+			pushNotifier, err := NewPushNotifier(&config.PushConfig)
+			if err != nil {
+				log.Fatalf("cannot create push client: %s", err)
+			}
+			notifiers = append(notifiers, pushNotifier)
+		}
+		if config.HasMQTT() {
+			mqttNotifier, err := NewMQTTNotifier(&config.MQTTConfig)
+			if err != nil {
+				log.Fatalf("cannot create mqtt client: %s", err)
+			}
+			notifiers = append(notifiers, mqttNotifier)
+		}
+		if config.HasMastodon() {
+			mastodonNotifier, err := NewMastodonNotifier(&config.MastodonConfig, db)
+			if err != nil {
+				log.Fatalf("cannot create mastodon client: %s", err)
+			}
+			notifiers = append(notifiers, mastodonNotifier)
+		}
+	}
+
+	// deliver notifications stored in the outbox
+	dispatcher, err := NewDispatcher(db, notifiers, config.OutboxConfig)
+	if err != nil {
+		log.Fatalf("cannot create dispatcher: %s", err)
 	}
 
 	// register filters
@@ -202,9 +316,55 @@ func main() {
 	parsers := []Parser{}
 
 	if config.HasURLs() {
+		// load queries to parse shops
+		registry, err := NewShopRegistryFromConfig(config.QueriesDir, config.Queries)
+		if err != nil {
+			log.Fatalf("cannot load shop queries: %s", err)
+		}
+
 		// create a parser for all web pages
 		for _, url := range config.URLs {
-			parser := NewURLParser(url, config.BrowserAddress)
+			shopName, err := ExtractShopName(url)
+			if err != nil || !registry.Supports(shopName) {
+				log.Warnf("could not create parser for url %s: shop not supported", url)
+				continue
+			}
+			parser := NewURLParser(url, config.BrowserAddress, registry)
+			parsers = append(parsers, parser)
+			log.Debugf("parser %s registered", parser)
+		}
+	}
+
+	if config.HasSchemaOrg() {
+		// create a parser for all web pages with schema.org metadata
+		for _, url := range config.SchemaOrgConfig.URLs {
+			parser := NewSchemaOrgParser(url, config.SchemaOrgConfig.MaxPages, config.SchemaOrgConfig.UserAgent, config.SchemaOrgConfig.Timeout)
+			parsers = append(parsers, parser)
+			log.Debugf("parser %s registered", parser)
+		}
+	}
+
+	if config.HasSelectors() {
+		// create a parser for all shops configured with CSS selectors
+		for _, selectorConfig := range config.Selectors {
+			parser, err := NewSelectorParser(selectorConfig, config.BrowserAddress)
+			if err != nil {
+				log.Warnf("could not create selector parser for url %s: %s", selectorConfig.URL, err)
+				continue
+			}
+			parsers = append(parsers, parser)
+			log.Debugf("parser %s registered", parser)
+		}
+	}
+
+	if config.HasProductPages() {
+		// create a parser for each watched product page
+		for _, productPageConfig := range config.ProductPages {
+			parser, err := NewProductPageParser(productPageConfig, config.BrowserAddress)
+			if err != nil {
+				log.Warnf("could not create product page parser for url %s: %s", productPageConfig.URL, err)
+				continue
+			}
 			parsers = append(parsers, parser)
 			log.Debugf("parser %s registered", parser)
 		}
@@ -238,53 +398,88 @@ func main() {
 		}
 	}
 
-	// parse asynchronously
-	var wg sync.WaitGroup
-	jobsCount := 0
-
-	for _, parser := range parsers {
-		for {
-			if jobsCount < *workers {
-				wg.Add(1)
-				jobsCount++
-				go handleProducts(parser, notifiers, filters, db, &wg)
-				break
-			} else {
-				log.Debugf("waiting for intermediate jobs to end")
-				wg.Wait()
-				jobsCount = 0
-			}
+	// detect price drops only when thresholds are configured
+	var priceDrop *PriceDropConfig
+	if config.HasPriceDrop() {
+		priceDrop = &config.PriceDropConfig
+	}
+
+	// start workers
+	pool := NewWorkerPool(*workers, time.Duration(*parseTimeout)*time.Second, func(ctx context.Context, parser Parser) (int, error) {
+		count, err := handleProducts(ctx, parser, dispatcher, filters, priceDrop, db)
+		// deliver new events and retry failed deliveries
+		dispatcher.Dispatch()
+		return count, err
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	// parse periodically until a termination signal is received
+	if *daemon {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		go dispatcher.Run(ctx)
+		if telegramBot != nil {
+			go telegramBot.Listen(ctx)
 		}
+		NewDaemon(config.DaemonConfig, parsers, pool).Run(ctx)
+		log.Infof("daemon stopped")
+		return
+	}
+
+	if telegramBot != nil {
+		log.Warnf("telegram bot commands are only handled in daemon mode")
 	}
 
-	log.Debugf("waiting for all jobs to end")
-	wg.Wait()
+	// parse asynchronously
+	results := pool.RunAll(parsers)
+
+	// deliver events skipped while other deliveries were in progress
+	dispatcher.Dispatch()
+
+	// print summary
+	for _, result := range results {
+		if result.Err != nil {
+			log.Warnf("%s", result)
+		} else {
+			log.Infof("%s", result)
+		}
+	}
 }
 
 // For parser to return a list of products, then eventually send notifications
-func handleProducts(parser Parser, notifiers []Notifier, filters []Filter, db *gorm.DB, wg *sync.WaitGroup) {
-	defer wg.Done()
-
+// Returns the number of parsed products
+func handleProducts(ctx context.Context, parser Parser, dispatcher *Dispatcher, filters []Filter, priceDrop *PriceDropConfig, db *gorm.DB) (int, error) {
 	log.Debugf("parsing with %s", parser)
 
 	// read shop from database or create it
 	var shop Shop
 	shopName, err := parser.ShopName()
 	if err != nil {
-		log.Warnf("cannot extract shop name from parser: %s", err)
-		return
+		return 0, fmt.Errorf("cannot extract shop name from parser: %s", err)
 	}
 	trx := db.Where(Shop{Name: shopName}).FirstOrCreate(&shop)
 	if trx.Error != nil {
-		log.Warnf("cannot create or select shop %s to/from database: %s", shopName, trx.Error)
-		return
+		return 0, fmt.Errorf("cannot create or select shop %s to/from database: %s", shopName, trx.Error)
+	}
+
+	// keep track of this execution, even when parsing fails
+	run := NewShopRun(shop, parser)
+	count, err := handleShopProducts(ctx, parser, shop, dispatcher, filters, priceDrop, db)
+	run.End(count, err)
+	if trx = db.Create(run); trx.Error != nil {
+		log.Warnf("cannot save execution of %s to database: %s", parser, trx.Error)
 	}
 
+	return count, err
+}
+
+// Parse products of a shop, update the database then eventually send notifications
+func handleShopProducts(ctx context.Context, parser Parser, shop Shop, dispatcher *Dispatcher, filters []Filter, priceDrop *PriceDropConfig, db *gorm.DB) (int, error) {
 	// parse products
-	products, err := parser.Parse()
+	products, err := parser.Parse(ctx)
 	if err != nil {
-		log.Warnf("cannot parse: %s", err)
-		return
+		return 0, fmt.Errorf("cannot parse: %s", err)
 	}
 
 	for _, product := range products {
@@ -327,6 +522,20 @@ func handleProducts(parser Parser, notifiers []Notifier, filters []Filter, db *g
 		}
 		log.Debugf("product %s found in database", dbProduct.Name)
 
+		// keep the state of existing products before the change
+		var previous *Product
+		if count > 0 {
+			p := dbProduct
+			previous = &p
+		}
+
+		// start price history of new products
+		if count == 0 {
+			if trx = db.Create(NewPriceHistory(&dbProduct)); trx.Error != nil {
+				log.Warnf("cannot save price of product %s to database: %s", dbProduct.Name, trx.Error)
+			}
+		}
+
 		// detect availability change
 		duration := time.Now().Sub(dbProduct.UpdatedAt).Truncate(time.Second)
 		createThread := false
@@ -349,32 +558,58 @@ func handleProducts(parser Parser, notifiers []Notifier, filters []Filter, db *g
 			}
 		}
 
-		// update product in database before sending notification
+		// existing product still available with a lower price than the previous run
+		priceDropped := false
+		if priceDrop != nil && count > 0 && dbProduct.Available && product.Available && dbProduct.PriceDropped(product, priceDrop.Amount, priceDrop.Percentage) {
+			log.Infof("price of product %s on %s dropped from %s to %s", product.Name, shop.Name, formatPrice(dbProduct.Price, dbProduct.PriceCurrency), formatPrice(product.Price, product.PriceCurrency))
+			priceDropped = true
+		}
+
+		// select the event to notify
+		eventType := ""
+		if duration > 0 {
+			switch {
+			case createThread:
+				eventType = EventAvailable
+			case closeThread:
+				eventType = EventNotAvailable
+			case priceDropped:
+				eventType = EventPriceDrop
+			}
+		}
+
+		// update product in database and store the event in the outbox within the same transaction
 		// if there is a database failure, we don't want the bot to send a notification at each run
-		if dbProduct.ToMerge(product) {
+		// and the notification can't be lost once the product has been updated
+		if dbProduct.ToMerge(product) || eventType != "" {
+			priceChanged := dbProduct.PriceChanged(product)
 			dbProduct.Merge(product)
-			trx = db.Save(&dbProduct)
-			if trx.Error != nil {
-				log.Warnf("cannot save product %s to database: %s", dbProduct.Name, trx.Error)
+			err = db.Transaction(func(tx *gorm.DB) error {
+				if trx := tx.Save(&dbProduct); trx.Error != nil {
+					return trx.Error
+				}
+				// keep track of price changes
+				if priceChanged {
+					if trx := tx.Create(NewPriceHistory(&dbProduct)); trx.Error != nil {
+						return trx.Error
+					}
+				}
+				if eventType != "" {
+					return dispatcher.Enqueue(tx, NewRestockEvent(eventType, shop, dbProduct, previous, duration))
+				}
+				return nil
+			})
+			if err != nil {
+				log.Warnf("cannot save product %s to database: %s", dbProduct.Name, err)
 				continue
 			}
 			log.Debugf("product %s updated in database", dbProduct.Name)
 		}
 
-		// send notifications
-		if duration > 0 {
-			if createThread {
-				for _, notifier := range notifiers {
-					if err := notifier.NotifyWhenAvailable(shop.Name, dbProduct.Name, dbProduct.Price, dbProduct.PriceCurrency, dbProduct.URL); err != nil {
-						log.Errorf("%s", err)
-					}
-				}
-			} else if closeThread {
-				for _, notifier := range notifiers {
-					if err := notifier.NotifyWhenNotAvailable(dbProduct.URL, duration); err != nil {
-						log.Errorf("%s", err)
-					}
-				}
+		// keep track of availability changes
+		if createThread || closeThread {
+			if trx = db.Create(NewAvailabilityEvent(&dbProduct)); trx.Error != nil {
+				log.Warnf("cannot save availability event of product %s to database: %s", dbProduct.Name, trx.Error)
 			}
 		}
 
@@ -385,6 +620,8 @@ func handleProducts(parser Parser, notifiers []Notifier, filters []Filter, db *g
 		}
 
 	}
+
+	return len(products), nil
 }
 
 func showVersion() {
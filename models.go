@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -41,7 +43,22 @@ func (p *Product) Merge(o *Product) {
 
 // ToMerge detects if a product needs to be merged with another one
 func (p *Product) ToMerge(o *Product) bool {
-	return p.Price != o.Price || p.PriceCurrency != o.PriceCurrency || p.Available != o.Available
+	return p.PriceChanged(o) || p.Available != o.Available
+}
+
+// PriceChanged detects if the price or the currency of a product is different from another one
+func (p *Product) PriceChanged(o *Product) bool {
+	return p.Price != o.Price || p.PriceCurrency != o.PriceCurrency
+}
+
+// PriceDropped detects if the price of a product has decreased by at least the given amount or percentage compared to another one
+// Thresholds equal to zero are ignored
+func (p *Product) PriceDropped(o *Product, amount float64, percentage float64) bool {
+	if p.PriceCurrency != o.PriceCurrency || p.Price <= 0 || o.Price >= p.Price {
+		return false
+	}
+	drop := p.Price - o.Price
+	return (amount > 0 && drop >= amount) || (percentage > 0 && drop*100/p.Price >= percentage)
 }
 
 // Shop represents a retailer website
@@ -49,3 +66,63 @@ type Shop struct {
 	ID   uint   `gorm:"primaryKey"`
 	Name string `gorm:"unique" json:"name"`
 }
+
+// PriceHistory stores the price of a product from a point in time
+type PriceHistory struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ProductID     uint      `gorm:"not null;index" json:"product_id"`
+	Product       Product   `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Price         float64   `gorm:"not null" json:"price"`
+	PriceCurrency string    `gorm:"not null" json:"price_currency"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+// NewPriceHistory creates a PriceHistory from the current price of a product
+func NewPriceHistory(p *Product) *PriceHistory {
+	return &PriceHistory{
+		ProductID:     p.ID,
+		Price:         p.Price,
+		PriceCurrency: p.PriceCurrency,
+	}
+}
+
+const (
+	// ShopRunSuccess is the status of a successful parser execution
+	ShopRunSuccess = "success"
+	// ShopRunFailure is the status of a failed parser execution
+	ShopRunFailure = "failure"
+)
+
+// ShopRun stores the outcome of a parser execution for a shop
+type ShopRun struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ShopID       uint      `gorm:"index" json:"shop_id"`
+	Shop         Shop      `gorm:"constraint:OnDelete:CASCADE" json:"shop"`
+	ParserName   string    `gorm:"not null" json:"parser_name"`
+	StartedAt    time.Time `gorm:"not null;index" json:"started_at"`
+	EndedAt      time.Time `json:"ended_at"`
+	Status       string    `gorm:"not null;index" json:"status"`
+	ErrorMessage string    `json:"error_message"`
+	ProductCount int       `gorm:"not null;default:0" json:"product_count"`
+}
+
+// NewShopRun starts a ShopRun for a shop parsed by a parser
+func NewShopRun(shop Shop, parser Parser) *ShopRun {
+	return &ShopRun{
+		ShopID:     shop.ID,
+		ParserName: parser.String(),
+		StartedAt:  time.Now(),
+	}
+}
+
+// End a ShopRun with the number of products and the error returned by the parser
+func (r *ShopRun) End(productCount int, err error) {
+	r.EndedAt = time.Now()
+	r.ProductCount = productCount
+	if err != nil {
+		r.Status = ShopRunFailure
+		r.ErrorMessage = err.Error()
+	} else {
+		r.Status = ShopRunSuccess
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShopRunEnd(t *testing.T) {
+	tests := []struct {
+		count    int
+		err      error
+		status   string
+		errorMsg string
+	}{
+		{3, nil, ShopRunSuccess, ""},                          // successful execution
+		{0, nil, ShopRunSuccess, ""},                          // successful execution without product
+		{0, fmt.Errorf("timeout"), ShopRunFailure, "timeout"}, // failed execution
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestShopRunEnd#%d", i), func(t *testing.T) {
+			run := NewShopRun(Shop{ID: 1, Name: "shop.com"}, &fakeParser{shopName: "shop.com"})
+			run.End(tc.count, tc.err)
+			if run.Status != tc.status || run.ErrorMessage != tc.errorMsg || run.ProductCount != tc.count {
+				t.Errorf("got %+v, want status=%s, error=%s, count=%d", run, tc.status, tc.errorMsg, tc.count)
+			} else if run.EndedAt.Before(run.StartedAt) {
+				t.Errorf("got end time %s before start time %s", run.EndedAt, run.StartedAt)
+			} else {
+				t.Logf("got %+v", run)
+			}
+		})
+	}
+}
+
+func TestProductPriceChanged(t *testing.T) {
+	tests := []struct {
+		product *Product
+		other   *Product
+		changed bool
+		toMerge bool
+	}{
+		{&Product{Price: 99.99, PriceCurrency: "EUR"}, &Product{Price: 99.99, PriceCurrency: "EUR"}, false, false},                 // same product
+		{&Product{Price: 99.99, PriceCurrency: "EUR"}, &Product{Price: 89.99, PriceCurrency: "EUR"}, true, true},                   // price change
+		{&Product{Price: 99.99, PriceCurrency: "EUR"}, &Product{Price: 99.99, PriceCurrency: "USD"}, true, true},                   // currency change
+		{&Product{Price: 99.99, PriceCurrency: "EUR"}, &Product{Price: 99.99, PriceCurrency: "EUR", Available: true}, false, true}, // availability change
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestProductPriceChanged#%d", i), func(t *testing.T) {
+			changed := tc.product.PriceChanged(tc.other)
+			toMerge := tc.product.ToMerge(tc.other)
+			if changed != tc.changed || toMerge != tc.toMerge {
+				t.Errorf("for %+v and %+v, got changed=%t and toMerge=%t, want changed=%t and toMerge=%t", tc.product, tc.other, changed, toMerge, tc.changed, tc.toMerge)
+			} else {
+				t.Logf("for %+v and %+v, got changed=%t and toMerge=%t", tc.product, tc.other, changed, toMerge)
+			}
+		})
+	}
+}
+
+func TestProductPriceDropped(t *testing.T) {
+	tests := []struct {
+		other      *Product
+		amount     float64
+		percentage float64
+		dropped    bool
+	}{
+		{&Product{Price: 749.99, PriceCurrency: "EUR"}, 100, 0, true},  // absolute drop
+		{&Product{Price: 849.99, PriceCurrency: "EUR"}, 100, 0, false}, // absolute drop below threshold
+		{&Product{Price: 749.99, PriceCurrency: "EUR"}, 0, 10, true},   // percentage drop
+		{&Product{Price: 849.99, PriceCurrency: "EUR"}, 0, 10, false},  // percentage drop below threshold
+		{&Product{Price: 849.99, PriceCurrency: "EUR"}, 100, 5, true},  // one of the thresholds
+		{&Product{Price: 949.99, PriceCurrency: "EUR"}, 1, 1, false},   // price increase
+		{&Product{Price: 749.99, PriceCurrency: "USD"}, 1, 1, false},   // currency change
+		{&Product{Price: 749.99, PriceCurrency: "EUR"}, 0, 0, false},   // no threshold
+	}
+
+	product := &Product{Price: 899.99, PriceCurrency: "EUR"}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestProductPriceDropped#%d", i), func(t *testing.T) {
+			dropped := product.PriceDropped(tc.other, tc.amount, tc.percentage)
+			if dropped != tc.dropped {
+				t.Errorf("for %+v with amount=%.2f and percentage=%.2f, got %t, want %t", tc.other, tc.amount, tc.percentage, dropped, tc.dropped)
+			} else {
+				t.Logf("for %+v with amount=%.2f and percentage=%.2f, got %t", tc.other, tc.amount, tc.percentage, dropped)
+			}
+		})
+	}
+}
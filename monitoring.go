@@ -55,8 +55,9 @@ func FormatMonitoringResults(results []MonitoringResult) string {
 	return strings.Join(s, ", ")
 }
 
-// Monitor will check for last execution time for each shop and return either
+// Monitor will check for last successful execution time for each shop and return either
 // a warning or critical alert when the threshold has been reached
+// A warning is also raised when the last execution has failed
 func Monitor(db *gorm.DB, warningTimeout int, criticalTimeout int) (rc int) {
 
 	// Find date and time thresholds
@@ -81,9 +82,17 @@ func Monitor(db *gorm.DB, warningTimeout int, criticalTimeout int) (rc int) {
 			ReturnCode: NagiosOk,
 		}
 
-		// Fetch last execution time
-		var product Product
-		trx := db.Where(Product{ShopID: shop.ID}).Order("updated_at desc").First(&product)
+		// Fetch last successful execution
+		var lastSuccess ShopRun
+		trx := db.Where(ShopRun{ShopID: shop.ID, Status: ShopRunSuccess}).Order("started_at desc").First(&lastSuccess)
+		if trx.Error == gorm.ErrRecordNotFound {
+			// Fall back to the last product update for databases created before shop runs
+			var product Product
+			trx = db.Where(Product{ShopID: shop.ID}).Order("updated_at desc").First(&product)
+			if trx.Error == nil {
+				lastSuccess.EndedAt = product.UpdatedAt
+			}
+		}
 		if trx.Error == gorm.ErrRecordNotFound {
 			result.Message = "has not been updated"
 			result.ReturnCode = NagiosCritical
@@ -95,15 +104,25 @@ func Monitor(db *gorm.DB, warningTimeout int, criticalTimeout int) (rc int) {
 			return NagiosUnknown
 		}
 
+		// Fetch last execution to detect failures
+		var lastRun ShopRun
+		trx = db.Where(ShopRun{ShopID: shop.ID}).Order("started_at desc").First(&lastRun)
+		if trx.Error != nil && trx.Error != gorm.ErrRecordNotFound {
+			fmt.Printf("%s\n", trx.Error)
+			return NagiosUnknown
+		}
+
 		// Compare to thresholds and add to result map
-		diff := int(time.Now().Sub(product.UpdatedAt.Local()).Seconds())
+		diff := int(time.Now().Sub(lastSuccess.EndedAt.Local()).Seconds())
 		result.Message = fmt.Sprintf("updated %d seconds ago", diff)
 
-		if product.UpdatedAt.Before(criticalTime) {
+		if lastSuccess.EndedAt.Before(criticalTime) {
 			result.ReturnCode = NagiosCritical
-		} else if product.UpdatedAt.Before(warningTime) {
+		} else if lastSuccess.EndedAt.Before(warningTime) {
+			result.ReturnCode = NagiosWarning
+		} else if lastRun.Status == ShopRunFailure {
+			result.Message = fmt.Sprintf("%s but last execution failed (%s)", result.Message, lastRun.ErrorMessage)
 			result.ReturnCode = NagiosWarning
-		} else {
 		}
 		log.Info(result)
 		resultMap[result.ReturnCode] = append(resultMap[result.ReturnCode], result)
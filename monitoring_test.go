@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		productUpdatedAt time.Time
+		runs             []ShopRun
+		expected         int
+	}{
+		{now, nil, NagiosOk},                            // no run yet, product updated recently
+		{now.Add(-2 * time.Hour), nil, NagiosWarning},   // no run yet, product updated a while ago
+		{now.Add(-48 * time.Hour), nil, NagiosCritical}, // no run yet, product not updated for long
+		{now.Add(-48 * time.Hour), []ShopRun{{Status: ShopRunSuccess, StartedAt: now, EndedAt: now}}, NagiosOk},
+		{now, []ShopRun{{Status: ShopRunSuccess, StartedAt: now.Add(-48 * time.Hour), EndedAt: now.Add(-48 * time.Hour)}}, NagiosCritical},
+		{now, []ShopRun{{Status: ShopRunSuccess, StartedAt: now.Add(-time.Minute), EndedAt: now.Add(-time.Minute)}, {Status: ShopRunFailure, StartedAt: now, EndedAt: now}}, NagiosWarning},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestMonitor#%d", i), func(t *testing.T) {
+			db, product := newNotifierTestDatabase(t)
+			if err := db.AutoMigrate(&ShopRun{}); err != nil {
+				t.Fatalf("cannot migrate database: %s", err)
+			}
+			db.Model(&product).UpdateColumn("updated_at", tc.productUpdatedAt)
+			for _, run := range tc.runs {
+				run.ShopID = product.ShopID
+				run.ParserName = "test"
+				db.Create(&run)
+			}
+
+			got := Monitor(db, 3600, 86400)
+			if got != tc.expected {
+				t.Errorf("got %d, want %d", got, tc.expected)
+			} else {
+				t.Logf("got %d", got)
+			}
+		})
+	}
+}
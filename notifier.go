@@ -2,13 +2,48 @@ package main
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 )
 
+// Types of events sent to notifiers
+const (
+	EventAvailable    = "available"
+	EventNotAvailable = "not_available"
+	EventPriceDrop    = "price_drop"
+)
+
 // Notifier interface to notify when a product becomes available or is sold out again
 type Notifier interface {
-	NotifyWhenAvailable(string, string, float64, string, string) error
-	NotifyWhenNotAvailable(string, time.Duration) error
+	Notify(*RestockEvent) error
+	String() string
+}
+
+// RestockEvent describes a change of a product sent to notifiers
+type RestockEvent struct {
+	Type      string
+	Product   Product       // current state of the product, including its shop
+	Previous  *Product      // state of the product before the change, nil for new products
+	Duration  time.Duration // time spent in the previous state
+	CreatedAt time.Time
+}
+
+// NewRestockEvent creates a RestockEvent of a product sold by a shop
+func NewRestockEvent(eventType string, shop Shop, product Product, previous *Product, duration time.Duration) *RestockEvent {
+	product.Shop = shop
+	if previous != nil {
+		p := *previous
+		p.Shop = shop
+		previous = &p
+	}
+	return &RestockEvent{
+		Type:      eventType,
+		Product:   product,
+		Previous:  previous,
+		Duration:  duration,
+		CreatedAt: time.Now(),
+	}
 }
 
 // formatPrice using internationalization rules
@@ -24,3 +59,45 @@ func formatPrice(value float64, currency string) string {
 		return fmt.Sprintf("%s%.2f", currency, value)
 	}
 }
+
+// matchPatterns returns the value of the first rule with a pattern matching the lowercase product name
+// Rules are ordered lists of pattern/value maps (ex: [{"rtx 3060( )?ti": "#rtx3060ti"}, {"rtx 3060": "#rtx3060"}])
+func matchPatterns(rules []map[string]string, productName string) string {
+	productName = strings.ToLower(productName)
+	for _, rule := range rules {
+		for pattern, value := range rule {
+			if ok, _ := regexp.MatchString(pattern, productName); ok {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// built-in templates of short statuses (Twitter, Mastodon)
+var statusTemplates = map[string]messageTemplateTexts{
+	LanguageEnglish: {
+		available:    "{{.Shop}}: {{.Name}} for {{.FormattedPrice}} is available at {{.URL}} {{.Hashtags}}{{if gt .Counter 1}} ({{.Counter}}){{end}}",
+		notAvailable: "And it's gone ({{.Duration}})",
+		priceDrop:    "Price drop: {{.FormattedOldPrice}} → {{.FormattedPrice}}",
+	},
+	LanguageFrench: {
+		available:    "{{.Shop}} : {{.Name}} à {{.FormattedPrice}} est disponible sur {{.URL}} {{.Hashtags}}{{if gt .Counter 1}} ({{.Counter}}){{end}}",
+		notAvailable: "Et c'est fini ({{.Duration}})",
+		priceDrop:    "Baisse de prix : {{.FormattedOldPrice}} → {{.FormattedPrice}}",
+	},
+}
+
+// built-in templates of plain text messages (Matrix, Email)
+var textTemplates = map[string]messageTemplateTexts{
+	LanguageEnglish: {
+		available:    "Name: {{.Name}}\nRetailer: {{.Shop}}\nPrice: {{.FormattedPrice}}\nURL: {{.URL}}\nDate/Time: {{.Date}}",
+		notAvailable: "And it's gone ({{.Duration}})",
+		subject:      "{{.Name}} is available at {{.Shop}} for {{.FormattedPrice}}",
+	},
+	LanguageFrench: {
+		available:    "Nom : {{.Name}}\nVendeur : {{.Shop}}\nPrix : {{.FormattedPrice}}\nURL : {{.URL}}\nDate/Heure : {{.Date}}",
+		notAvailable: "Et c'est fini ({{.Duration}})",
+		subject:      "{{.Name}} est disponible chez {{.Shop}} à {{.FormattedPrice}}",
+	},
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// colors of Discord embeds
+const (
+	discordColorAvailable    = 0x2ecc71
+	discordColorNotAvailable = 0xe74c3c
+)
+
+// built-in templates of Discord embeds and follow-up messages
+var discordTemplates = map[string]messageTemplateTexts{
+	LanguageEnglish: {
+		available:    "**Retailer:** {{.Shop}}\n**Price:** {{.FormattedPrice}}\n**URL:** [go to website]({{.URL}})",
+		notAvailable: "And it's gone ({{.Duration}}): {{.URL}}",
+	},
+	LanguageFrench: {
+		available:    "**Vendeur :** {{.Shop}}\n**Prix :** {{.FormattedPrice}}\n**URL :** [voir le site]({{.URL}})",
+		notAvailable: "Et c'est fini ({{.Duration}}) : {{.URL}}",
+	},
+}
+
+// DiscordMessage to store relationship between a Product and a Discord notification
+type DiscordMessage struct {
+	gorm.Model
+	MessageID  string `gorm:"not null;unique"`
+	ProductURL string
+	Product    Product `gorm:"not null;references:URL;constraint:OnDelete:CASCADE"`
+}
+
+// DiscordNotifier to manage notifications to a Discord webhook
+type DiscordNotifier struct {
+	db            *gorm.DB
+	client        *http.Client
+	webhookURL    string
+	username      string
+	avatarURL     string
+	enableReplies bool
+	editMessages  bool
+	templates     *MessageTemplates
+}
+
+// discordWebhookMessage to send messages to a Discord webhook
+// See https://discord.com/developers/docs/resources/webhook#execute-webhook
+type discordWebhookMessage struct {
+	Username  string         `json:"username,omitempty"`
+	AvatarURL string         `json:"avatar_url,omitempty"`
+	Content   string         `json:"content,omitempty"`
+	Embeds    []discordEmbed `json:"embeds,omitempty"`
+}
+
+// discordEmbed to format rich messages
+type discordEmbed struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Color       int    `json:"color,omitempty"`
+	Timestamp   string `json:"timestamp,omitempty"`
+}
+
+// NewDiscordNotifier to create a Notifier with Discord capabilities
+func NewDiscordNotifier(config *DiscordConfig, db *gorm.DB) (*DiscordNotifier, error) {
+	// create table
+	err := db.AutoMigrate(&DiscordMessage{})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = url.Parse(config.WebhookURL); err != nil {
+		return nil, fmt.Errorf("invalid discord webhook url: %s", err)
+	}
+
+	templates, err := NewMessageTemplates(config.Templates, discordTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiscordNotifier{
+		db:            db,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		webhookURL:    config.WebhookURL,
+		username:      config.Username,
+		avatarURL:     config.AvatarURL,
+		enableReplies: config.EnableReplies,
+		editMessages:  config.EditMessages,
+		templates:     templates,
+	}, nil
+}
+
+// String returns the name of the notifier
+func (n *DiscordNotifier) String() string {
+	return "discord"
+}
+
+// Notify sends a notification when a product becomes available or is sold out again
+// implements the Notifier interface
+func (n *DiscordNotifier) Notify(event *RestockEvent) error {
+	switch event.Type {
+	case EventAvailable:
+		return n.notifyWhenAvailable(event)
+	case EventNotAvailable:
+		return n.notifyWhenNotAvailable(event)
+	}
+	return nil
+}
+
+// notifyWhenAvailable create a Discord message for announcing that a product is available
+func (n *DiscordNotifier) notifyWhenAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+	embed, err := n.formatAvailableEmbed(NewMessageData(event), time.Now())
+	if err != nil {
+		return err
+	}
+	messageID, err := n.send(http.MethodPost, "", discordWebhookMessage{Embeds: []discordEmbed{embed}})
+	if err != nil {
+		return err
+	}
+	log.Infof("message %s sent to discord", messageID)
+
+	// save discord message to database
+	m := DiscordMessage{MessageID: messageID, ProductURL: productURL}
+	trx := n.db.Create(&m)
+	if trx.Error != nil {
+		return fmt.Errorf("failed to save discord message %s to database: %s", m.MessageID, trx.Error)
+	}
+	log.Debugf("discord message %s saved to database", m.MessageID)
+
+	return nil
+}
+
+// notifyWhenNotAvailable edit the notifyWhenAvailable message and/or send a follow-up message to say it's gone
+func (n *DiscordNotifier) notifyWhenNotAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+
+	// find message in the database
+	var m DiscordMessage
+	trx := n.db.Where(DiscordMessage{ProductURL: productURL}).First(&m)
+	if trx.Error == gorm.ErrRecordNotFound {
+		// product was already available before the notifier was enabled
+		log.Debugf("discord message for product with url %s not found, skipping not available notification", productURL)
+		return nil
+	}
+	if trx.Error != nil {
+		return fmt.Errorf("failed to find discord message in database for product with url %s: %s", productURL, trx.Error)
+	}
+
+	data := NewMessageData(event)
+	text, err := n.templates.NotAvailable(data)
+	if err != nil {
+		return err
+	}
+
+	if n.editMessages {
+		embed, err := n.formatAvailableEmbed(data, m.CreatedAt)
+		if err != nil {
+			return err
+		}
+		embed.Color = discordColorNotAvailable
+		embed.Description = fmt.Sprintf("%s\n\n%s", embed.Description, text)
+		if _, err := n.send(http.MethodPatch, m.MessageID, discordWebhookMessage{Embeds: []discordEmbed{embed}}); err != nil {
+			return fmt.Errorf("failed to edit discord message %s: %s", m.MessageID, err)
+		}
+		log.Infof("discord message %s edited", m.MessageID)
+	}
+
+	if n.enableReplies {
+		messageID, err := n.send(http.MethodPost, "", discordWebhookMessage{Content: text})
+		if err != nil {
+			return fmt.Errorf("failed to send follow-up message on discord: %s", err)
+		}
+		log.Infof("follow-up of discord message %s sent with id %s", m.MessageID, messageID)
+	}
+
+	// remove message from database
+	trx = n.db.Unscoped().Delete(&m)
+	if trx.Error != nil {
+		return fmt.Errorf("failed to remove message %s from database: %s", m.MessageID, trx.Error)
+	}
+	log.Debugf("discord message removed from database")
+	return nil
+}
+
+// formatAvailableEmbed creates an embed based on product characteristics
+func (n *DiscordNotifier) formatAvailableEmbed(data *MessageData, date time.Time) (discordEmbed, error) {
+	description, err := n.templates.Available(data)
+	if err != nil {
+		return discordEmbed{}, err
+	}
+	return discordEmbed{
+		Title:       data.Name,
+		Description: description,
+		URL:         data.URL,
+		Color:       discordColorAvailable,
+		Timestamp:   date.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// send a message to the webhook, or edit a message when an ID is given, and return the message ID
+func (n *DiscordNotifier) send(method string, messageID string, message discordWebhookMessage) (string, error) {
+	message.Username = n.username
+	message.AvatarURL = n.avatarURL
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint, err := url.Parse(n.webhookURL)
+	if err != nil {
+		return "", err
+	}
+	if messageID != "" {
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/messages/" + messageID
+	}
+	// wait for the message to be created to get its ID
+	query := endpoint.Query()
+	query.Set("wait", "true")
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create new request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	log.Debugf("sending message %s to discord", body)
+	res, err := n.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", fmt.Errorf("discord returned %d: %s", res.StatusCode, content)
+	}
+
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err = json.Unmarshal(content, &response); err != nil {
+		return "", fmt.Errorf("failed to decode discord response: %s", err)
+	}
+	return response.ID, nil
+}
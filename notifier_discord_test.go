@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// discordRequest stores a request received by the fake webhook
+type discordRequest struct {
+	method  string
+	path    string
+	message discordWebhookMessage
+}
+
+func TestDiscordNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var requests []discordRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var message discordWebhookMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("cannot decode message: %s", err)
+		}
+		if r.URL.Query().Get("wait") != "true" {
+			t.Errorf("got wait=%s, want wait=true", r.URL.Query().Get("wait"))
+		}
+		requests = append(requests, discordRequest{method: r.Method, path: r.URL.Path, message: message})
+		fmt.Fprintf(w, `{"id": "%d"}`, 1000+len(requests))
+	}))
+	defer server.Close()
+
+	db, product := newNotifierTestDatabase(t)
+
+	notifier, err := NewDiscordNotifier(&DiscordConfig{WebhookURL: server.URL + "/api/webhooks/1/token", Username: "restockbot", EnableReplies: true, EditMessages: true}, db)
+	if err != nil {
+		t.Fatalf("cannot create notifier: %s", err)
+	}
+
+	if err = notifier.Notify(newAvailableEvent(product)); err != nil {
+		t.Fatalf("cannot notify when available: %s", err)
+	}
+	if err = notifier.Notify(newNotAvailableEvent(product, 90*time.Second)); err != nil {
+		t.Fatalf("cannot notify when not available: %s", err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		color  int    // color of the embed, 0 without embed
+		field  string // last line of the embed description or content
+	}{
+		{http.MethodPost, "/api/webhooks/1/token", discordColorAvailable, "**URL:** [go to website](https://www.ldlc.com/fiche/PB00385720.html)"},
+		{http.MethodPatch, "/api/webhooks/1/token/messages/1001", discordColorNotAvailable, "And it's gone (1m30s): https://www.ldlc.com/fiche/PB00385720.html"},
+		{http.MethodPost, "/api/webhooks/1/token", 0, "And it's gone (1m30s): https://www.ldlc.com/fiche/PB00385720.html"},
+	}
+
+	if len(requests) != len(tests) {
+		t.Fatalf("got %d requests, want %d", len(requests), len(tests))
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestDiscordNotifier#%d", i), func(t *testing.T) {
+			got := requests[i]
+			if got.method != tc.method || got.path != tc.path || got.message.Username != "restockbot" {
+				t.Errorf("got %s %s as %s, want %s %s as restockbot", got.method, got.path, got.message.Username, tc.method, tc.path)
+			}
+			var color int
+			var field string
+			if len(got.message.Embeds) > 0 {
+				embed := got.message.Embeds[0]
+				color = embed.Color
+				lines := strings.Split(embed.Description, "\n")
+				field = lines[len(lines)-1]
+				if embed.Title != product.Name || !strings.Contains(embed.Description, "**Price:** 899.99€") {
+					t.Errorf("got embed %+v, want title %s and price 899.99€", embed, product.Name)
+				}
+			} else {
+				field = got.message.Content
+			}
+			if color != tc.color || field != tc.field {
+				t.Errorf("got color %x and value '%s', want color %x and value '%s'", color, field, tc.color, tc.field)
+			} else {
+				t.Logf("got %+v", got)
+			}
+		})
+	}
+
+	var count int64
+	db.Model(&DiscordMessage{}).Count(&count)
+	if count != 0 {
+		t.Errorf("got %d discord messages in database, want 0", count)
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Encryption modes supported by the EmailNotifier
+const (
+	EmailTLSNone     = "none"
+	EmailTLSStartTLS = "starttls"
+	EmailTLSImplicit = "tls"
+)
+
+// maximum time to connect and to send an email to the SMTP server
+const emailTimeout = 30 * time.Second
+
+// EmailMessage to store relationship between a Product and an email notification
+type EmailMessage struct {
+	gorm.Model
+	MessageID  string `gorm:"not null;unique"`
+	Subject    string
+	ProductURL string
+	Product    Product `gorm:"not null;references:URL;constraint:OnDelete:CASCADE"`
+}
+
+// EmailNotifier to manage notifications by email
+type EmailNotifier struct {
+	db            *gorm.DB
+	host          string
+	port          int
+	username      string
+	password      string
+	from          *mail.Address
+	to            []string
+	tlsMode       string
+	enableReplies bool
+	templates     *MessageTemplates
+}
+
+// formatEmailHTML wraps a plain text message into an HTML body
+func formatEmailHTML(text string, productURL string) string {
+	return fmt.Sprintf("<html>\n<body>\n<p>%s</p>\n</body>\n</html>\n", textToHTML(text, productURL))
+}
+
+// NewEmailNotifier to create a Notifier with email capabilities
+func NewEmailNotifier(config *EmailConfig, db *gorm.DB) (*EmailNotifier, error) {
+	// create table
+	err := db.AutoMigrate(&EmailMessage{})
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %s", err)
+	}
+	for _, to := range config.To {
+		if _, err = mail.ParseAddress(to); err != nil {
+			return nil, fmt.Errorf("invalid recipient address: %s", err)
+		}
+	}
+
+	tlsMode := config.TLS
+	if tlsMode == "" {
+		tlsMode = EmailTLSStartTLS
+	}
+	if !ContainsString([]string{EmailTLSNone, EmailTLSStartTLS, EmailTLSImplicit}, tlsMode) {
+		return nil, fmt.Errorf("tls mode %s not supported", tlsMode)
+	}
+
+	templates, err := NewMessageTemplates(config.Templates, textTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 587
+		if tlsMode == EmailTLSImplicit {
+			port = 465
+		}
+	}
+
+	return &EmailNotifier{
+		db:            db,
+		host:          config.Host,
+		port:          port,
+		username:      config.Username,
+		password:      config.Password,
+		from:          from,
+		to:            config.To,
+		tlsMode:       tlsMode,
+		enableReplies: config.EnableReplies,
+		templates:     templates,
+	}, nil
+}
+
+// String returns the name of the notifier
+func (n *EmailNotifier) String() string {
+	return "email"
+}
+
+// Notify sends a notification when a product becomes available or is sold out again
+// implements the Notifier interface
+func (n *EmailNotifier) Notify(event *RestockEvent) error {
+	switch event.Type {
+	case EventAvailable:
+		return n.notifyWhenAvailable(event)
+	case EventNotAvailable:
+		return n.notifyWhenNotAvailable(event)
+	}
+	return nil
+}
+
+// notifyWhenAvailable sends an email for announcing that a product is available
+func (n *EmailNotifier) notifyWhenAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+	data := NewMessageData(event)
+	subject, err := n.templates.Subject(data)
+	if err != nil {
+		return err
+	}
+	text, err := n.templates.Available(data)
+	if err != nil {
+		return err
+	}
+
+	messageID := n.newMessageID()
+	message, err := n.buildMessage(messageID, subject, "", text+"\n", formatEmailHTML(text, productURL))
+	if err != nil {
+		return err
+	}
+	if err = n.send(message); err != nil {
+		return fmt.Errorf("failed to send email for product with url %s: %s", productURL, err)
+	}
+	log.Infof("email %s sent", messageID)
+
+	// save email to database
+	m := EmailMessage{MessageID: messageID, Subject: subject, ProductURL: productURL}
+	trx := n.db.Create(&m)
+	if trx.Error != nil {
+		return fmt.Errorf("failed to save email %s to database: %s", m.MessageID, trx.Error)
+	}
+	log.Debugf("email %s saved to database", m.MessageID)
+
+	return nil
+}
+
+// notifyWhenNotAvailable sends an email replying to the notifyWhenAvailable email to say it's gone
+func (n *EmailNotifier) notifyWhenNotAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+
+	// find email in the database
+	var m EmailMessage
+	trx := n.db.Where(EmailMessage{ProductURL: productURL}).First(&m)
+	if trx.Error == gorm.ErrRecordNotFound {
+		// product was already available before the notifier was enabled
+		log.Debugf("email for product with url %s not found, skipping not available notification", productURL)
+		return nil
+	}
+	if trx.Error != nil {
+		return fmt.Errorf("failed to find email in database for product with url %s: %s", productURL, trx.Error)
+	}
+
+	if n.enableReplies {
+		text, err := n.templates.NotAvailable(NewMessageData(event))
+		if err != nil {
+			return err
+		}
+		message, err := n.buildMessage(n.newMessageID(), "Re: "+m.Subject, m.MessageID, text+"\n", formatEmailHTML(text, productURL))
+		if err != nil {
+			return err
+		}
+		if err = n.send(message); err != nil {
+			return fmt.Errorf("failed to reply to email %s: %s", m.MessageID, err)
+		}
+		log.Infof("reply to email %s sent", m.MessageID)
+	}
+
+	// remove email from database
+	trx = n.db.Unscoped().Delete(&m)
+	if trx.Error != nil {
+		return fmt.Errorf("failed to remove email %s from database: %s", m.MessageID, trx.Error)
+	}
+	log.Debugf("email removed from database")
+	return nil
+}
+
+// newMessageID generates a unique Message-ID using the domain of the sender
+func (n *EmailNotifier) newMessageID() string {
+	domain := "restockbot"
+	if i := strings.LastIndex(n.from.Address, "@"); i >= 0 {
+		domain = n.from.Address[i+1:]
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("<%d.%x@%s>", time.Now().UnixNano(), b, domain)
+}
+
+// buildMessage creates a multipart message with plain text and HTML bodies
+// The message is part of a thread when inReplyTo is not empty
+func (n *EmailNotifier) buildMessage(messageID string, subject string, inReplyTo string, text string, html string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{{"text/plain", text}, {"text/html", html}} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType+"; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err = qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		qp.Close()
+	}
+	writer.Close()
+
+	var message bytes.Buffer
+	headers := [][2]string{
+		{"From", n.from.String()},
+		{"To", strings.Join(n.to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+	}
+	if inReplyTo != "" {
+		headers = append(headers, [2]string{"In-Reply-To", inReplyTo}, [2]string{"References", inReplyTo})
+	}
+	headers = append(headers,
+		[2]string{"MIME-Version", "1.0"},
+		[2]string{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%s", writer.Boundary())},
+	)
+	for _, header := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", header[0], header[1])
+	}
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// send a message to all recipients through the SMTP server
+func (n *EmailNotifier) send(message []byte) error {
+	address := net.JoinHostPort(n.host, strconv.Itoa(n.port))
+	tlsConfig := &tls.Config{ServerName: n.host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: emailTimeout}
+	if n.tlsMode == EmailTLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot connect to %s: %s", address, err)
+	}
+
+	// a server accepting the connection then hanging must not block notifications
+	if err = conn.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+		conn.Close()
+		return fmt.Errorf("cannot set deadline on connection to %s: %s", address, err)
+	}
+
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if n.tlsMode == EmailTLSStartTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("cannot start tls: %s", err)
+		}
+	}
+	if n.username != "" {
+		if err = client.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return fmt.Errorf("cannot authenticate: %s", err)
+		}
+	}
+
+	log.Debugf("sending email to %s through %s", strings.Join(n.to, ", "), address)
+	if err = client.Mail(n.from.Address); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		recipient, _ := mail.ParseAddress(to)
+		if err = client.Rcpt(recipient.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(message); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
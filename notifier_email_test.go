@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startSMTPServer starts a minimal SMTP server storing received messages
+func startSMTPServer(t *testing.T) (string, int, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					command := strings.ToUpper(strings.TrimSpace(line))
+					switch {
+					case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+						fmt.Fprint(conn, "250 localhost\r\n")
+					case command == "DATA":
+						fmt.Fprint(conn, "354 go ahead\r\n")
+						var data strings.Builder
+						for {
+							line, err = reader.ReadString('\n')
+							if err != nil || line == ".\r\n" {
+								break
+							}
+							data.WriteString(line)
+						}
+						messages <- data.String()
+						fmt.Fprint(conn, "250 ok\r\n")
+					case command == "QUIT":
+						fmt.Fprint(conn, "221 bye\r\n")
+						return
+					default:
+						fmt.Fprint(conn, "250 ok\r\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return "127.0.0.1", listener.Addr().(*net.TCPAddr).Port, messages
+}
+
+func TestEmailNotifier(t *testing.T) {
+	host, port, messages := startSMTPServer(t)
+	db, product := newNotifierTestDatabase(t)
+
+	notifier, err := NewEmailNotifier(&EmailConfig{
+		Host:          host,
+		Port:          port,
+		TLS:           EmailTLSNone,
+		From:          "Restockbot <restockbot@example.com>",
+		To:            []string{"alice@example.com", "Bob <bob@example.com>"},
+		EnableReplies: true,
+	}, db)
+	if err != nil {
+		t.Fatalf("cannot create notifier: %s", err)
+	}
+
+	if err = notifier.Notify(newAvailableEvent(product)); err != nil {
+		t.Fatalf("cannot notify when available: %s", err)
+	}
+	if err = notifier.Notify(newNotAvailableEvent(product, 90*time.Second)); err != nil {
+		t.Fatalf("cannot notify when not available: %s", err)
+	}
+
+	var received []*mail.Message
+	for i := 0; i < 2; i++ {
+		select {
+		case data := <-messages:
+			message, err := mail.ReadMessage(strings.NewReader(data))
+			if err != nil {
+				t.Fatalf("cannot read message: %s", err)
+			}
+			received = append(received, message)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d not received", i)
+		}
+	}
+
+	decoder := new(mime.WordDecoder)
+	available, gone := received[0].Header, received[1].Header
+	subject, _ := decoder.DecodeHeader(available.Get("Subject"))
+	replySubject, _ := decoder.DecodeHeader(gone.Get("Subject"))
+
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"subject", subject, "MSI GeForce RTX 3080 GAMING X is available at ldlc.com for 899.99€"},
+		{"recipients", available.Get("To"), "alice@example.com, Bob <bob@example.com>"},
+		{"reply subject", replySubject, "Re: " + subject},
+		{"in reply to", gone.Get("In-Reply-To"), available.Get("Message-ID")},
+		{"references", gone.Get("References"), available.Get("Message-ID")},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestEmailNotifier#%d", i), func(t *testing.T) {
+			if tc.got != tc.expected {
+				t.Errorf("for %s, got '%s', want '%s'", tc.name, tc.got, tc.expected)
+			} else {
+				t.Logf("for %s, got '%s'", tc.name, tc.got)
+			}
+		})
+	}
+
+	// check both parts of the multipart message
+	_, params, err := mime.ParseMediaType(available.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("cannot parse content type: %s", err)
+	}
+	reader := multipart.NewReader(received[0].Body, params["boundary"])
+	for _, contentType := range []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"} {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("cannot read part %s: %s", contentType, err)
+		}
+		content, _ := ioutil.ReadAll(part)
+		if part.Header.Get("Content-Type") != contentType || !bytes.Contains(content, []byte(product.URL)) {
+			t.Errorf("got part %s with content %s, want part %s with product url", part.Header.Get("Content-Type"), content, contentType)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// DefaultMastodonMaxCharacters when the instance doesn't expose its limit
+const DefaultMastodonMaxCharacters = 500
+
+// MastodonStatus to store relationship between a Product and a Mastodon notification
+type MastodonStatus struct {
+	gorm.Model
+	StatusID     string  `gorm:"not null;unique"`
+	Hash         string  `gorm:"unique"`
+	LastStatusID string  `gorm:"index"`
+	Counter      int64   `gorm:"not null;default:1"`
+	ProductURL   string  `gorm:"index"`
+	Product      Product `gorm:"not null;references:URL;constraint:OnDelete:CASCADE"`
+}
+
+// MastodonNotifier to manage notifications to Mastodon
+type MastodonNotifier struct {
+	db            *gorm.DB
+	client        *http.Client
+	instanceURL   string
+	accessToken   string
+	visibility    string
+	maxCharacters int
+	hashtagsMap   []map[string]string
+	enableReplies bool
+	retentionDays int
+	templates     *MessageTemplates
+}
+
+// mastodonStatus returned by the statuses API
+type mastodonStatus struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// NewMastodonNotifier creates a MastodonNotifier
+func NewMastodonNotifier(c *MastodonConfig, db *gorm.DB) (*MastodonNotifier, error) {
+	// create table
+	err := db.AutoMigrate(&MastodonStatus{})
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := NewMessageTemplates(c.Templates, statusTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	notifier := &MastodonNotifier{
+		db:            db,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		instanceURL:   strings.TrimSuffix(c.InstanceURL, "/"),
+		accessToken:   c.AccessToken,
+		visibility:    c.Visibility,
+		maxCharacters: c.MaxCharacters,
+		hashtagsMap:   c.Hashtags,
+		enableReplies: c.EnableReplies,
+		retentionDays: c.Retention,
+		templates:     templates,
+	}
+
+	// verify credentials at least once
+	var account struct {
+		Acct string `json:"acct"`
+	}
+	if err = notifier.request(http.MethodGet, "/api/v1/accounts/verify_credentials", nil, &account); err != nil {
+		return nil, err
+	}
+	log.Debugf("connected to mastodon as @%s", account.Acct)
+
+	// find the maximum size of statuses
+	if notifier.maxCharacters == 0 {
+		notifier.maxCharacters = notifier.findMaxCharacters()
+	}
+
+	// delete old statuses
+	if err = notifier.ensureRetention(); err != nil {
+		return nil, err
+	}
+
+	return notifier, nil
+}
+
+// findMaxCharacters returns the maximum size of statuses from the instance configuration
+func (c *MastodonNotifier) findMaxCharacters() int {
+	var instance struct {
+		Configuration struct {
+			Statuses struct {
+				MaxCharacters int `json:"max_characters"`
+			} `json:"statuses"`
+		} `json:"configuration"`
+	}
+	if err := c.request(http.MethodGet, "/api/v2/instance", nil, &instance); err != nil {
+		log.Debugf("cannot find mastodon maximum characters: %s", err)
+	}
+	if instance.Configuration.Statuses.MaxCharacters > 0 {
+		return instance.Configuration.Statuses.MaxCharacters
+	}
+	return DefaultMastodonMaxCharacters
+}
+
+// ensureRetention deletes statuses according to the defined retention
+func (c *MastodonNotifier) ensureRetention() error {
+	if c.retentionDays == 0 {
+		log.Debugf("mastodon retention not found, skipping database cleanup")
+		return nil
+	}
+
+	var oldStatuses []MastodonStatus
+	retentionDate := time.Now().Local().Add(-time.Hour * 24 * time.Duration(c.retentionDays))
+	trx := c.db.Where("updated_at < ?", retentionDate).Find(&oldStatuses)
+	if trx.Error != nil {
+		return fmt.Errorf("cannot find mastodon old statuses: %s", trx.Error)
+	}
+	for _, s := range oldStatuses {
+		log.Debugf("mastodon old status found with id %s", s.StatusID)
+		if trx = c.db.Unscoped().Delete(&s); trx.Error != nil {
+			log.Warnf("cannot remove old mastodon status %s: %s", s.StatusID, trx.Error)
+		} else {
+			log.Infof("mastodon old status %s removed from database", s.StatusID)
+		}
+	}
+	return nil
+}
+
+// request calls the Mastodon API and decodes the response
+func (c *MastodonNotifier) request(method string, path string, values url.Values, response interface{}) error {
+	var body *strings.Reader
+	if values != nil {
+		body = strings.NewReader(values.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	req, err := http.NewRequest(method, c.instanceURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create new request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	if values != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("mastodon returned %d: %s", res.StatusCode, content)
+	}
+	return json.Unmarshal(content, response)
+}
+
+// create a new status, replying to another status when inReplyToID is not empty
+func (c *MastodonNotifier) createStatus(message string, inReplyToID string) (string, error) {
+	values := url.Values{}
+	values.Set("status", message)
+	if c.visibility != "" {
+		values.Set("visibility", c.visibility)
+	}
+	if inReplyToID != "" {
+		values.Set("in_reply_to_id", inReplyToID)
+	}
+
+	var status mastodonStatus
+	if err := c.request(http.MethodPost, "/api/v1/statuses", values, &status); err != nil {
+		return "", err
+	}
+	log.Debugf("mastodon status %s created: %s", status.ID, status.Content)
+	return status.ID, nil
+}
+
+// parse product name to build a list of hashtags
+func (c *MastodonNotifier) buildHashtags(productName string) string {
+	return matchPatterns(c.hashtagsMap, productName)
+}
+
+// String returns the name of the notifier
+func (c *MastodonNotifier) String() string {
+	return "mastodon"
+}
+
+// Notify sends a notification when a product becomes available or is sold out again
+// implements the Notifier interface
+func (c *MastodonNotifier) Notify(event *RestockEvent) error {
+	switch event.Type {
+	case EventAvailable:
+		return c.notifyWhenAvailable(event)
+	case EventNotAvailable:
+		return c.notifyWhenNotAvailable(event)
+	}
+	return nil
+}
+
+// notifyWhenAvailable create a Mastodon status for announcing that a product is available
+func (c *MastodonNotifier) notifyWhenAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+
+	// format message
+	data := NewMessageData(event)
+	data.Hashtags = c.buildHashtags(event.Product.Name)
+	message, err := c.templates.AvailableWithMaxSize(data, c.maxCharacters)
+	if err != nil {
+		return err
+	}
+
+	// compute message checksum to avoid duplicates
+	var status MastodonStatus
+	hash := fmt.Sprintf("%x", md5.Sum([]byte(message)))
+	trx := c.db.Where(MastodonStatus{Hash: hash}).First(&status)
+	if trx.Error != nil && trx.Error != gorm.ErrRecordNotFound {
+		return fmt.Errorf("could not search for mastodon status with hash %s for product '%s': %s", hash, productURL, trx.Error)
+	}
+
+	if trx.Error == gorm.ErrRecordNotFound {
+
+		// status has not been sent in the past
+		// create thread
+		statusID, err := c.createStatus(message, "")
+		if err != nil {
+			return fmt.Errorf("could not create new mastodon thread for product '%s': %s", productURL, err)
+		}
+		log.Infof("mastodon status %s sent for product '%s'", statusID, productURL)
+
+		// save thread to database
+		status = MastodonStatus{StatusID: statusID, ProductURL: productURL, Hash: hash, Counter: 1}
+		trx = c.db.Create(&status)
+		if trx.Error != nil {
+			return fmt.Errorf("could not save mastodon status %s to database for product '%s': %s", status.StatusID, productURL, trx.Error)
+		}
+		log.Debugf("mastodon status %s saved to database", status.StatusID)
+
+	} else {
+
+		// status already has been sent in the past
+		// creating new thread with a counter
+		status.Counter++
+		data.Counter = status.Counter
+		message, err = c.templates.AvailableWithMaxSize(data, c.maxCharacters)
+		if err != nil {
+			return err
+		}
+		statusID, err := c.createStatus(message, "")
+		if err != nil {
+			return fmt.Errorf("could not create new mastodon thread for product '%s': %s", productURL, err)
+		}
+		log.Infof("mastodon status %s sent for product '%s'", statusID, productURL)
+
+		// save thread to database
+		status.LastStatusID = statusID
+		if trx = c.db.Save(&status); trx.Error != nil {
+			return fmt.Errorf("could not save mastodon status %s to database for product '%s': %s", status.StatusID, productURL, trx.Error)
+		}
+		log.Debugf("mastodon status %s saved to database", status.StatusID)
+	}
+
+	return nil
+}
+
+// notifyWhenNotAvailable create a Mastodon status replying to the notifyWhenAvailable status to say it's over
+func (c *MastodonNotifier) notifyWhenNotAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+
+	// find status in the database
+	var status MastodonStatus
+	trx := c.db.Where(MastodonStatus{ProductURL: productURL}).First(&status)
+	if trx.Error == gorm.ErrRecordNotFound {
+		// product was already available before the notifier was enabled
+		log.Debugf("mastodon status for product '%s' not found, skipping not available notification", productURL)
+		return nil
+	}
+	if trx.Error != nil {
+		return fmt.Errorf("could not find mastodon status for product '%s' in the database: %s", productURL, trx.Error)
+	}
+
+	if c.enableReplies {
+		// format message
+		message, err := c.templates.NotAvailable(NewMessageData(event))
+		if err != nil {
+			return err
+		}
+
+		// select status to reply
+		lastStatusID := status.LastStatusID
+		if lastStatusID == "" {
+			lastStatusID = status.StatusID
+		}
+
+		// close thread on mastodon
+		statusID, err := c.createStatus(message, lastStatusID)
+		if err != nil {
+			return fmt.Errorf("could not close thread on mastodon for product '%s': %s", productURL, err)
+		}
+		log.Infof("reply to mastodon status %s sent with id %s for product '%s'", lastStatusID, statusID, productURL)
+
+		// save status id on database
+		status.LastStatusID = statusID
+		if trx = c.db.Save(&status); trx.Error != nil {
+			return fmt.Errorf("could not save mastodon status %s to database for product '%s': %s", status.StatusID, productURL, trx.Error)
+		}
+		log.Debugf("mastodon status %s saved in database", status.StatusID)
+	} else {
+		log.Debugf("mastodon replies are disabled, skipping not available notification for '%s'", productURL)
+	}
+
+	return nil
+}
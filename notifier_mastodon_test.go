@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestMastodonNotifier(t *testing.T) {
+	var statuses []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/accounts/verify_credentials":
+			fmt.Fprint(w, `{"acct": "restockbot"}`)
+		case "/api/v2/instance":
+			fmt.Fprint(w, `{"configuration": {"statuses": {"max_characters": 120}}}`)
+		case "/api/v1/statuses":
+			r.ParseForm()
+			statuses = append(statuses, map[string]string{"status": r.PostForm.Get("status"), "in_reply_to_id": r.PostForm.Get("in_reply_to_id"), "visibility": r.PostForm.Get("visibility")})
+			fmt.Fprintf(w, `{"id": "%d", "content": "<p>status</p>"}`, 100+len(statuses))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	db, product := newNotifierTestDatabase(t)
+	notifier, err := NewMastodonNotifier(&MastodonConfig{
+		InstanceURL:   server.URL + "/",
+		AccessToken:   "token",
+		Visibility:    "unlisted",
+		Hashtags:      []map[string]string{{"rtx 3080": "#nvidia #rtx3080"}},
+		EnableReplies: true,
+	}, db)
+	if err != nil {
+		t.Fatalf("cannot create notifier: %s", err)
+	}
+
+	notifyWhenAvailable := func() error {
+		return notifier.Notify(newAvailableEvent(product))
+	}
+	notifyWhenNotAvailable := func() error {
+		return notifier.Notify(newNotAvailableEvent(product, 90*time.Second))
+	}
+
+	tests := []struct {
+		notify      func() error
+		status      string
+		inReplyToID string
+	}{
+		{notifyWhenAvailable, "ldlc.com: MSI GeForce RTX 3080 … for 899.99€ is available at https://www.ldlc.com/fiche/PB00385720.html #nvidia #rtx3080", ""},
+		{notifyWhenNotAvailable, "And it's gone (1m30s)", "101"},
+		{notifyWhenAvailable, "ldlc.com: MSI GeForce RTX 3… for 899.99€ is available at https://www.ldlc.com/fiche/PB00385720.html #nvidia #rtx3080 (2)", ""}, // same message with counter
+		{notifyWhenNotAvailable, "And it's gone (1m30s)", "103"}, // reply to the last status
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestMastodonNotifier#%d", i), func(t *testing.T) {
+			if err := tc.notify(); err != nil {
+				t.Fatalf("cannot notify: %s", err)
+			}
+			got := statuses[len(statuses)-1]
+			if got["status"] != tc.status || got["in_reply_to_id"] != tc.inReplyToID || got["visibility"] != "unlisted" {
+				t.Errorf("got %+v, want status '%s' replying to '%s'", got, tc.status, tc.inReplyToID)
+			} else {
+				t.Logf("got %+v", got)
+			}
+			if utf8.RuneCountInString(got["status"]) > notifier.maxCharacters {
+				t.Errorf("got status of %d characters, want less than %d", utf8.RuneCountInString(got["status"]), notifier.maxCharacters)
+			}
+		})
+	}
+}
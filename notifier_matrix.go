@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// MatrixEvent to store relationship between a Product and a Matrix notification
+type MatrixEvent struct {
+	gorm.Model
+	EventID    string `gorm:"not null;unique"`
+	ProductURL string
+	Product    Product `gorm:"not null;references:URL;constraint:OnDelete:CASCADE"`
+}
+
+// MatrixNotifier to manage notifications to a Matrix room
+type MatrixNotifier struct {
+	db            *gorm.DB
+	client        *http.Client
+	homeserverURL string
+	accessToken   string
+	roomID        string
+	enableReplies bool
+	transactions  uint64
+	templates     *MessageTemplates
+}
+
+// matrixMessage to send m.room.message events
+// See https://spec.matrix.org/latest/client-server-api/#mroommessage
+type matrixMessage struct {
+	MsgType       string          `json:"msgtype"`
+	Body          string          `json:"body"`
+	Format        string          `json:"format,omitempty"`
+	FormattedBody string          `json:"formatted_body,omitempty"`
+	RelatesTo     *matrixRelation `json:"m.relates_to,omitempty"`
+}
+
+// matrixRelation to send a message in a thread
+// See https://spec.matrix.org/latest/client-server-api/#threading
+type matrixRelation struct {
+	RelType       string            `json:"rel_type"`
+	EventID       string            `json:"event_id"`
+	IsFallingBack bool              `json:"is_falling_back"`
+	InReplyTo     map[string]string `json:"m.in_reply_to"`
+}
+
+// NewMatrixNotifier to create a Notifier with Matrix capabilities
+func NewMatrixNotifier(config *MatrixConfig, db *gorm.DB) (*MatrixNotifier, error) {
+	// create table
+	err := db.AutoMigrate(&MatrixEvent{})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = url.Parse(config.HomeserverURL); err != nil {
+		return nil, fmt.Errorf("invalid matrix homeserver url: %s", err)
+	}
+
+	templates, err := NewMessageTemplates(config.Templates, textTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MatrixNotifier{
+		db:            db,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		homeserverURL: strings.TrimSuffix(config.HomeserverURL, "/"),
+		accessToken:   config.AccessToken,
+		roomID:        config.RoomID,
+		enableReplies: config.EnableReplies,
+		templates:     templates,
+	}, nil
+}
+
+// String returns the name of the notifier
+func (n *MatrixNotifier) String() string {
+	return "matrix"
+}
+
+// Notify sends a notification when a product becomes available or is sold out again
+// implements the Notifier interface
+func (n *MatrixNotifier) Notify(event *RestockEvent) error {
+	switch event.Type {
+	case EventAvailable:
+		return n.notifyWhenAvailable(event)
+	case EventNotAvailable:
+		return n.notifyWhenNotAvailable(event)
+	}
+	return nil
+}
+
+// notifyWhenAvailable create a Matrix message for announcing that a product is available
+func (n *MatrixNotifier) notifyWhenAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+	body, err := n.templates.Available(NewMessageData(event))
+	if err != nil {
+		return err
+	}
+	message := matrixMessage{
+		MsgType:       "m.text",
+		Body:          body,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: textToHTML(body, productURL),
+	}
+	eventID, err := n.sendMessage(message)
+	if err != nil {
+		return err
+	}
+
+	// save matrix event to database
+	m := MatrixEvent{EventID: eventID, ProductURL: productURL}
+	trx := n.db.Create(&m)
+	if trx.Error != nil {
+		return fmt.Errorf("failed to save matrix event %s to database: %s", m.EventID, trx.Error)
+	}
+	log.Debugf("matrix event %s saved to database", m.EventID)
+
+	return nil
+}
+
+// notifyWhenNotAvailable create a Matrix message in the thread of the notifyWhenAvailable message to say it's gone
+func (n *MatrixNotifier) notifyWhenNotAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+
+	// find event in the database
+	var m MatrixEvent
+	trx := n.db.Where(MatrixEvent{ProductURL: productURL}).First(&m)
+	if trx.Error == gorm.ErrRecordNotFound {
+		// product was already available before the notifier was enabled
+		log.Debugf("matrix event for product with url %s not found, skipping not available notification", productURL)
+		return nil
+	}
+	if trx.Error != nil {
+		return fmt.Errorf("failed to find matrix event in database for product with url %s: %s", productURL, trx.Error)
+	}
+
+	if n.enableReplies {
+		body, err := n.templates.NotAvailable(NewMessageData(event))
+		if err != nil {
+			return err
+		}
+		message := matrixMessage{
+			MsgType: "m.text",
+			Body:    body,
+			RelatesTo: &matrixRelation{
+				RelType:       "m.thread",
+				EventID:       m.EventID,
+				IsFallingBack: true,
+				InReplyTo:     map[string]string{"event_id": m.EventID},
+			},
+		}
+		if _, err := n.sendMessage(message); err != nil {
+			return fmt.Errorf("failed to reply on matrix: %s", err)
+		}
+		log.Infof("reply to matrix event %s sent", m.EventID)
+	}
+
+	// remove event from database
+	trx = n.db.Unscoped().Delete(&m)
+	if trx.Error != nil {
+		return fmt.Errorf("failed to remove matrix event %s from database: %s", m.EventID, trx.Error)
+	}
+	log.Debugf("matrix event removed from database")
+	return nil
+}
+
+// sendMessage sends a message to the room and returns the event ID
+func (n *MatrixNotifier) sendMessage(message matrixMessage) (string, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+
+	// transaction identifier to make the request idempotent
+	txnID := fmt.Sprintf("restockbot.%d.%d", time.Now().UnixNano(), atomic.AddUint64(&n.transactions, 1))
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", n.homeserverURL, url.PathEscape(n.roomID), txnID)
+
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create new request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.accessToken)
+
+	log.Debugf("sending message %s to matrix", body)
+	res, err := n.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("matrix returned %d: %s", res.StatusCode, content)
+	}
+
+	var response struct {
+		EventID string `json:"event_id"`
+	}
+	if err = json.Unmarshal(content, &response); err != nil {
+		return "", fmt.Errorf("failed to decode matrix response: %s", err)
+	}
+	log.Infof("message %s sent to matrix", response.EventID)
+	return response.EventID, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatrixNotifier(t *testing.T) {
+	var paths []string
+	var messages []matrixMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var message matrixMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("cannot decode message: %s", err)
+		}
+		paths = append(paths, r.URL.EscapedPath())
+		messages = append(messages, message)
+		fmt.Fprintf(w, `{"event_id": "$event%d"}`, len(messages))
+	}))
+	defer server.Close()
+
+	db, product := newNotifierTestDatabase(t)
+
+	notifier, err := NewMatrixNotifier(&MatrixConfig{HomeserverURL: server.URL + "/", AccessToken: "token", RoomID: "!room:matrix.org", EnableReplies: true}, db)
+	if err != nil {
+		t.Fatalf("cannot create notifier: %s", err)
+	}
+	if err = notifier.Notify(newAvailableEvent(product)); err != nil {
+		t.Fatalf("cannot notify when available: %s", err)
+	}
+	if err = notifier.Notify(newNotAvailableEvent(product, 90*time.Second)); err != nil {
+		t.Fatalf("cannot notify when not available: %s", err)
+	}
+
+	tests := []struct {
+		body     string // prefix of the body
+		threadID string // event replied to, empty without thread
+	}{
+		{"Name: MSI GeForce RTX 3080 GAMING X\nRetailer: ldlc.com\nPrice: 899.99€\nURL: https://www.ldlc.com/fiche/PB00385720.html", ""},
+		{"And it's gone (1m30s)", "$event1"},
+	}
+
+	if len(messages) != len(tests) {
+		t.Fatalf("got %d messages, want %d", len(messages), len(tests))
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestMatrixNotifier#%d", i), func(t *testing.T) {
+			got := messages[i]
+			if !strings.HasPrefix(paths[i], "/_matrix/client/v3/rooms/%21room:matrix.org/send/m.room.message/") {
+				t.Errorf("got path %s, want path to send a message to the room", paths[i])
+			}
+			var threadID string
+			if got.RelatesTo != nil {
+				if got.RelatesTo.RelType != "m.thread" || got.RelatesTo.InReplyTo["event_id"] != got.RelatesTo.EventID {
+					t.Errorf("got relation %+v, want thread relation", got.RelatesTo)
+				}
+				threadID = got.RelatesTo.EventID
+			}
+			if !strings.HasPrefix(got.Body, tc.body) || threadID != tc.threadID {
+				t.Errorf("got body '%s' in thread '%s', want body '%s' in thread '%s'", got.Body, threadID, tc.body, tc.threadID)
+			} else {
+				t.Logf("got %+v", got)
+			}
+		})
+	}
+
+	if paths[0] == paths[1] {
+		t.Errorf("got same transaction for both messages: %s", paths[0])
+	}
+}
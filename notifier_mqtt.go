@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMQTTTopicPrefix to publish states to restockbot/<shop>/<product-slug>-<url-hash> topics
+const DefaultMQTTTopicPrefix = "restockbot"
+
+// characters replaced in topic levels
+var mqttSlugRegex = regexp.MustCompile(`[^a-z0-9.]+`)
+
+// MQTTState to publish the state of a product
+type MQTTState struct {
+	Shop      string  `json:"shop"`
+	Name      string  `json:"name"`
+	URL       string  `json:"url"`
+	Price     float64 `json:"price"`
+	Currency  string  `json:"currency"`
+	Available bool    `json:"available"`
+	Duration  float64 `json:"duration,omitempty"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+// NewMQTTState creates the MQTTState of the product of an event
+func NewMQTTState(event *RestockEvent) *MQTTState {
+	product := event.Product
+	state := &MQTTState{
+		Shop:      product.Shop.Name,
+		Name:      product.Name,
+		URL:       product.URL,
+		Price:     product.Price,
+		Currency:  product.PriceCurrency,
+		Available: product.Available,
+		UpdatedAt: event.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if event.Type == EventNotAvailable {
+		state.Duration = event.Duration.Seconds()
+	}
+	return state
+}
+
+// MQTTNotifier to publish retained states of products to a MQTT broker
+type MQTTNotifier struct {
+	client      mqtt.Client
+	topicPrefix string
+	qos         byte
+	timeout     time.Duration
+}
+
+// NewMQTTNotifier to create a Notifier with MQTT capabilities
+func NewMQTTNotifier(config *MQTTConfig) (*MQTTNotifier, error) {
+	if config.QoS > 2 {
+		return nil, fmt.Errorf("qos %d not supported", config.QoS)
+	}
+
+	options := mqtt.NewClientOptions().AddBroker(config.Broker)
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("restockbot-%d", time.Now().UnixNano())
+	}
+	options.SetClientID(clientID)
+	options.SetUsername(config.Username)
+	options.SetPassword(config.Password)
+	options.SetAutoReconnect(true)
+
+	if config.CAFile != "" || config.InsecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+		if config.CAFile != "" {
+			ca, err := ioutil.ReadFile(config.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read ca file: %s", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("cannot load certificates from %s", config.CAFile)
+			}
+		}
+		options.SetTLSConfig(tlsConfig)
+	}
+
+	timeout := time.Duration(config.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	client := mqtt.NewClient(options)
+	token := client.Connect()
+	if !token.WaitTimeout(timeout) {
+		return nil, fmt.Errorf("timeout while connecting to %s", config.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("cannot connect to %s: %s", config.Broker, err)
+	}
+	log.Debugf("connected to mqtt broker %s as %s", config.Broker, clientID)
+
+	topicPrefix := strings.TrimSuffix(config.TopicPrefix, "/")
+	if topicPrefix == "" {
+		topicPrefix = DefaultMQTTTopicPrefix
+	}
+
+	return &MQTTNotifier{
+		client:      client,
+		topicPrefix: topicPrefix,
+		qos:         config.QoS,
+		timeout:     timeout,
+	}, nil
+}
+
+// String returns the name of the notifier
+func (n *MQTTNotifier) String() string {
+	return "mqtt"
+}
+
+// Notify publishes the state of a product when it becomes available, is sold out again or its price drops
+// implements the Notifier interface
+func (n *MQTTNotifier) Notify(event *RestockEvent) error {
+	switch event.Type {
+	case EventAvailable, EventNotAvailable, EventPriceDrop:
+		return n.publish(NewMQTTState(event))
+	}
+	return nil
+}
+
+// publish the retained state of a product
+func (n *MQTTNotifier) publish(state *MQTTState) error {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	topic := buildMQTTTopic(n.topicPrefix, state.Shop, state.Name, state.URL)
+	log.Debugf("publishing %s to mqtt topic %s", payload, topic)
+	token := n.client.Publish(topic, n.qos, true, payload)
+	if !token.WaitTimeout(n.timeout) {
+		return fmt.Errorf("timeout while publishing to mqtt topic %s", topic)
+	}
+	if err = token.Error(); err != nil {
+		return fmt.Errorf("cannot publish to mqtt topic %s: %s", topic, err)
+	}
+	log.Infof("state of product '%s' published to mqtt topic %s", state.URL, topic)
+	return nil
+}
+
+// buildMQTTTopic returns the topic of a product (ex: "restockbot/ldlc.com/msi-geforce-rtx-3080-gaming-x-e4e5dcde")
+// A hash of the URL distinguishes products sharing the same name in a shop
+func buildMQTTTopic(prefix string, shopName string, productName string, productURL string) string {
+	sum := sha256.Sum256([]byte(productURL))
+	return fmt.Sprintf("%s/%s/%s-%s", prefix, slugify(shopName), slugify(productName), hex.EncodeToString(sum[:])[:8])
+}
+
+// slugify converts a name to lowercase words separated by dashes, without MQTT wildcards and separators
+func slugify(name string) string {
+	return strings.Trim(mqttSlugRegex.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+}
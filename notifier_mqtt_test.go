@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestBuildMQTTTopic(t *testing.T) {
+	tests := []struct {
+		shopName    string
+		productName string
+		productURL  string
+		expected    string
+	}{
+		{"ldlc.com", "MSI GeForce RTX 3080 GAMING X", "https://www.ldlc.com/fiche/PB00385720.html", "restockbot/ldlc.com/msi-geforce-rtx-3080-gaming-x-e4e5dcde"},
+		{"ldlc.com", "MSI GeForce RTX 3080 GAMING X", "https://www.ldlc.com/fiche/PB00385721.html", "restockbot/ldlc.com/msi-geforce-rtx-3080-gaming-x-f711234a"}, // same name, other seller
+		{"www.steg-electronics.ch", "ASUS TUF RTX 3070 Ti O8G (8 Go)", "https://www.steg-electronics.ch/fr/article/asus-tuf-rtx3070ti-o8g-gaming-8go-gddr6x-9.aspx", "restockbot/www.steg-electronics.ch/asus-tuf-rtx-3070-ti-o8g-8-go-267b5aea"},
+		{"shop.com", "  wildcards +/# removed ", "https://shop.com/wildcards", "restockbot/shop.com/wildcards-removed-610c682b"},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestBuildMQTTTopic#%d", i), func(t *testing.T) {
+			got := buildMQTTTopic(DefaultMQTTTopicPrefix, tc.shopName, tc.productName, tc.productURL)
+			if got != tc.expected {
+				t.Errorf("for %s and %s, got %s, want %s", tc.shopName, tc.productName, got, tc.expected)
+			} else {
+				t.Logf("for %s and %s, got %s", tc.shopName, tc.productName, got)
+			}
+		})
+	}
+}
+
+func TestNewMQTTState(t *testing.T) {
+	_, product := newNotifierTestDatabase(t)
+
+	tests := []struct {
+		event     *RestockEvent
+		available bool
+		price     float64
+		duration  float64
+	}{
+		{newAvailableEvent(product), true, 899.99, 0},
+		{newPriceDropEvent(product, 799.99), true, 799.99, 0},
+		{newNotAvailableEvent(product, 90*time.Second), false, 899.99, 90},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestNewMQTTState#%d", i), func(t *testing.T) {
+			got := NewMQTTState(tc.event)
+			if got.Available != tc.available || got.Price != tc.price || got.Duration != tc.duration || got.URL != product.URL {
+				t.Errorf("for %s event, got %+v, want available=%t, price=%.2f and duration=%.0f", tc.event.Type, got, tc.available, tc.price, tc.duration)
+			} else {
+				t.Logf("for %s event, got %+v", tc.event.Type, got)
+			}
+		})
+	}
+}
+
+// TestMQTTNotifier requires a local broker (ex: MQTT_BROKER=tcp://127.0.0.1:1883)
+func TestMQTTNotifier(t *testing.T) {
+	broker := os.Getenv("MQTT_BROKER")
+	if broker == "" {
+		t.Skip("MQTT_BROKER not defined, skipping test against local broker")
+	}
+
+	_, product := newNotifierTestDatabase(t)
+	notifier, err := NewMQTTNotifier(&MQTTConfig{Broker: broker, QoS: 1, TopicPrefix: "restockbot-test", Timeout: 5})
+	if err != nil {
+		t.Fatalf("cannot create notifier: %s", err)
+	}
+	defer notifier.client.Disconnect(250)
+
+	tests := []struct {
+		notify    func() error
+		available bool
+		price     float64
+	}{
+		{func() error { return notifier.Notify(newAvailableEvent(product)) }, true, product.Price},
+		{func() error { return notifier.Notify(newPriceDropEvent(product, 799.99)) }, true, 799.99}, // state updated with the new price
+		{func() error { return notifier.Notify(newNotAvailableEvent(product, 90*time.Second)) }, false, product.Price},
+	}
+
+	topic := buildMQTTTopic("restockbot-test", product.Shop.Name, product.Name, product.URL)
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestMQTTNotifier#%d", i), func(t *testing.T) {
+			if err := tc.notify(); err != nil {
+				t.Fatalf("cannot notify: %s", err)
+			}
+
+			// retained message is received by new subscribers
+			subscriber := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID(fmt.Sprintf("restockbot-test-%d", i)))
+			token := subscriber.Connect()
+			if !token.WaitTimeout(5 * time.Second) {
+				t.Fatalf("timeout while connecting subscriber")
+			}
+			if token.Error() != nil {
+				t.Fatalf("cannot connect subscriber: %s", token.Error())
+			}
+			defer subscriber.Disconnect(250)
+
+			states := make(chan MQTTState, 1)
+			subscriber.Subscribe(topic, 1, func(_ mqtt.Client, message mqtt.Message) {
+				var state MQTTState
+				if err := json.Unmarshal(message.Payload(), &state); err != nil {
+					t.Errorf("cannot decode state: %s", err)
+				}
+				if !message.Retained() {
+					t.Errorf("got message not retained, want retained message")
+				}
+				states <- state
+			})
+
+			select {
+			case state := <-states:
+				if state.Available != tc.available || state.URL != product.URL || state.Price != tc.price {
+					t.Errorf("got %+v, want available=%t and price=%.2f for product %s", state, tc.available, tc.price, product.URL)
+				} else {
+					t.Logf("got %+v", state)
+				}
+			case <-time.After(5 * time.Second):
+				t.Errorf("no state received on topic %s", topic)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Push services supported by the PushNotifier
+const (
+	PushServiceNtfy   = "ntfy"
+	PushServiceGotify = "gotify"
+)
+
+// DefaultNtfyURL to publish messages to the public ntfy server
+const DefaultNtfyURL = "https://ntfy.sh"
+
+// default priorities by service and event type
+var defaultPushPriorities = map[string]map[string]int{
+	PushServiceNtfy:   {EventAvailable: 4, EventNotAvailable: 2},
+	PushServiceGotify: {EventAvailable: 8, EventNotAvailable: 2},
+}
+
+// PushNotifier to send push notifications to phones with ntfy or Gotify
+type PushNotifier struct {
+	client     *http.Client
+	service    string
+	url        string
+	topic      string
+	token      string
+	priorities map[string]int
+	tagsMap    []map[string]string
+	templates  *MessageTemplates
+}
+
+// built-in templates of push notifications, the product name is used as title
+var pushTemplates = map[string]messageTemplateTexts{
+	LanguageEnglish: {
+		available:    "Available at {{.Shop}} for {{.FormattedPrice}}",
+		notAvailable: "And it's gone ({{.Duration}})",
+	},
+	LanguageFrench: {
+		available:    "Disponible chez {{.Shop}} à {{.FormattedPrice}}",
+		notAvailable: "Et c'est fini ({{.Duration}})",
+	},
+}
+
+// ntfyMessage to publish messages as JSON
+// See https://docs.ntfy.sh/publish/#publish-as-json
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Tags     []string `json:"tags,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+	Click    string   `json:"click,omitempty"`
+}
+
+// gotifyMessage to create messages
+// See https://gotify.net/docs/msgextras
+type gotifyMessage struct {
+	Title    string                 `json:"title,omitempty"`
+	Message  string                 `json:"message"`
+	Priority int                    `json:"priority"`
+	Extras   map[string]interface{} `json:"extras,omitempty"`
+}
+
+// NewPushNotifier to create a Notifier with push capabilities
+func NewPushNotifier(config *PushConfig) (*PushNotifier, error) {
+	service := config.Service
+	if service == "" {
+		service = PushServiceNtfy
+	}
+	defaults, ok := defaultPushPriorities[service]
+	if !ok {
+		return nil, fmt.Errorf("push service %s not supported", service)
+	}
+
+	serverURL := config.URL
+	if serverURL == "" && service == PushServiceNtfy {
+		serverURL = DefaultNtfyURL
+	}
+	if serverURL == "" {
+		return nil, fmt.Errorf("url of the %s server is required", service)
+	}
+	if service == PushServiceNtfy && config.Topic == "" {
+		return nil, fmt.Errorf("ntfy topic is required")
+	}
+	if service == PushServiceGotify && config.Token == "" {
+		return nil, fmt.Errorf("gotify application token is required")
+	}
+
+	templates, err := NewMessageTemplates(config.Templates, pushTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	priorities := make(map[string]int)
+	for event, priority := range defaults {
+		priorities[event] = priority
+	}
+	for event, priority := range config.Priorities {
+		priorities[event] = priority
+	}
+
+	return &PushNotifier{
+		client:     &http.Client{Timeout: 30 * time.Second},
+		service:    service,
+		url:        strings.TrimSuffix(serverURL, "/"),
+		topic:      config.Topic,
+		token:      config.Token,
+		priorities: priorities,
+		tagsMap:    config.Tags,
+		templates:  templates,
+	}, nil
+}
+
+// String returns the name of the notifier
+func (n *PushNotifier) String() string {
+	return n.service
+}
+
+// Notify sends a push notification when a product becomes available or is sold out again
+// implements the Notifier interface
+func (n *PushNotifier) Notify(event *RestockEvent) error {
+	var message string
+	var err error
+	switch event.Type {
+	case EventAvailable:
+		message, err = n.templates.Available(NewMessageData(event))
+	case EventNotAvailable:
+		message, err = n.templates.NotAvailable(NewMessageData(event))
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return n.publish(event.Type, event.Product.Name, message, event.Product.URL)
+}
+
+// buildTags parses the product name to build a list of tags
+// Values of the tags map are separated by spaces or commas, leading "#" are removed to share maps with twitter hashtags
+func (n *PushNotifier) buildTags(productName string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(matchPatterns(n.tagsMap, productName), func(r rune) bool { return r == ',' || r == ' ' }) {
+		if tag = strings.TrimPrefix(tag, "#"); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// publish a notification to the configured service
+func (n *PushNotifier) publish(event string, title string, message string, productURL string) error {
+	tags := n.buildTags(title)
+	priority := n.priorities[event]
+
+	var endpoint string
+	var payload interface{}
+	switch n.service {
+	case PushServiceGotify:
+		// tags are not supported by gotify
+		if len(tags) > 0 {
+			message = fmt.Sprintf("%s #%s", message, strings.Join(tags, " #"))
+		}
+		endpoint = n.url + "/message"
+		payload = gotifyMessage{
+			Title:    title,
+			Message:  message,
+			Priority: priority,
+			Extras: map[string]interface{}{
+				"client::notification": map[string]interface{}{"click": map[string]string{"url": productURL}},
+			},
+		}
+	default:
+		endpoint = n.url
+		payload = ntfyMessage{Topic: n.topic, Title: title, Message: message, Tags: tags, Priority: priority, Click: productURL}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create new request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		if n.service == PushServiceGotify {
+			req.Header.Set("X-Gotify-Key", n.token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+n.token)
+		}
+	}
+
+	log.Debugf("sending push notification %s to %s", body, n.service)
+	res, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send push notification for product '%s': %s", productURL, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		content, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%s returned %d for product '%s': %s", n.service, res.StatusCode, productURL, content)
+	}
+	log.Infof("%s push notification sent to %s for product '%s'", event, n.service, productURL)
+	return nil
+}
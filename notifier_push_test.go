@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPushNotifier(t *testing.T) {
+	var path, authorization string
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		authorization = r.Header.Get("Authorization") + r.Header.Get("X-Gotify-Key")
+		payload = nil
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("cannot decode payload: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	_, product := newNotifierTestDatabase(t)
+	tags := []map[string]string{{"rtx 3080": "#nvidia #rtx3080"}}
+
+	tests := []struct {
+		config        PushConfig
+		available     bool
+		path          string
+		authorization string
+		expected      map[string]interface{}
+	}{
+		{ // ntfy with default priority
+			PushConfig{URL: server.URL, Topic: "restock", Tags: tags},
+			true, "/", "",
+			map[string]interface{}{"topic": "restock", "title": product.Name, "message": "Available at ldlc.com for 899.99€", "tags": []interface{}{"nvidia", "rtx3080"}, "priority": 4.0, "click": product.URL},
+		},
+		{ // ntfy with configured priority and name from database
+			PushConfig{URL: server.URL + "/", Topic: "restock", Token: "tk_secret", Priorities: map[string]int{"not_available": 1}},
+			false, "/", "Bearer tk_secret",
+			map[string]interface{}{"topic": "restock", "title": product.Name, "message": "And it's gone (1m30s)", "priority": 1.0, "click": product.URL},
+		},
+		{ // gotify with tags in message
+			PushConfig{Service: "gotify", URL: server.URL, Token: "app-token", Tags: tags},
+			true, "/message", "app-token",
+			map[string]interface{}{"title": product.Name, "message": "Available at ldlc.com for 899.99€ #nvidia #rtx3080", "priority": 8.0, "extras": map[string]interface{}{"client::notification": map[string]interface{}{"click": map[string]interface{}{"url": product.URL}}}},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestPushNotifier#%d", i), func(t *testing.T) {
+			notifier, err := NewPushNotifier(&tc.config)
+			if err != nil {
+				t.Fatalf("cannot create notifier: %s", err)
+			}
+			if tc.available {
+				err = notifier.Notify(newAvailableEvent(product))
+			} else {
+				err = notifier.Notify(newNotAvailableEvent(product, 90*time.Second))
+			}
+			if err != nil {
+				t.Fatalf("cannot notify: %s", err)
+			}
+			if path != tc.path || authorization != tc.authorization || !reflect.DeepEqual(payload, tc.expected) {
+				t.Errorf("got %s with authorization '%s' and payload %+v, want %s with authorization '%s' and payload %+v", path, authorization, payload, tc.path, tc.authorization, tc.expected)
+			} else {
+				t.Logf("got payload %+v", payload)
+			}
+		})
+	}
+}
+
+func TestNewPushNotifierErrors(t *testing.T) {
+	tests := []PushConfig{
+		{Service: "unknown", Topic: "restock"},           // unsupported service
+		{Service: "ntfy"},                                // missing topic
+		{Service: "gotify", Token: "app-token"},          // missing url
+		{Service: "gotify", URL: "https://gotify.local"}, // missing token
+	}
+	for i, config := range tests {
+		t.Run(fmt.Sprintf("TestNewPushNotifierErrors#%d", i), func(t *testing.T) {
+			if _, err := NewPushNotifier(&config); err == nil {
+				t.Errorf("got no error, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// DefaultSlackAPIURL to send messages with the Web API
+const DefaultSlackAPIURL = "https://slack.com/api"
+
+// built-in templates of Slack messages using mrkdwn
+var slackTemplates = map[string]messageTemplateTexts{
+	LanguageEnglish: {
+		available: `*Name:* {{.Name}}
+*Retailer:* {{.Shop}}
+*Price:* {{.FormattedPrice}}
+*URL:* <{{.URL}}|go to website>
+*Date/Time:* {{.Date}}`,
+		notAvailable: "And it's gone ({{.Duration}})",
+	},
+	LanguageFrench: {
+		available: `*Nom :* {{.Name}}
+*Vendeur :* {{.Shop}}
+*Prix :* {{.FormattedPrice}}
+*URL :* <{{.URL}}|voir le site>
+*Date/Heure :* {{.Date}}`,
+		notAvailable: "Et c'est fini ({{.Duration}})",
+	},
+}
+
+// SlackMessage to store relationship between a Product and a Slack notification
+type SlackMessage struct {
+	gorm.Model
+	TS         string `gorm:"not null;unique"`
+	Channel    string
+	ProductURL string
+	Product    Product `gorm:"not null;references:URL;constraint:OnDelete:CASCADE"`
+}
+
+// SlackNotifier to manage notifications to Slack
+// Messages are sent with the chat.postMessage API when a token is configured, to an incoming webhook otherwise
+// Incoming webhooks don't return message identifiers so replies can't be threaded
+type SlackNotifier struct {
+	db            *gorm.DB
+	client        *http.Client
+	token         string
+	channel       string
+	apiURL        string
+	webhookURL    string
+	enableReplies bool
+	templates     *MessageTemplates
+}
+
+// slackMessage to send messages to Slack
+// See https://api.slack.com/methods/chat.postMessage
+type slackMessage struct {
+	Channel     string `json:"channel,omitempty"`
+	Text        string `json:"text"`
+	ThreadTS    string `json:"thread_ts,omitempty"`
+	UnfurlLinks bool   `json:"unfurl_links"`
+}
+
+// slackResponse returned by the Web API
+type slackResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// NewSlackNotifier to create a Notifier with Slack capabilities
+func NewSlackNotifier(config *SlackConfig, db *gorm.DB) (*SlackNotifier, error) {
+	// create table
+	err := db.AutoMigrate(&SlackMessage{})
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := config.APIURL
+	if apiURL == "" {
+		apiURL = DefaultSlackAPIURL
+	}
+
+	templates, err := NewMessageTemplates(config.Templates, slackTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SlackNotifier{
+		db:            db,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		token:         config.Token,
+		channel:       config.Channel,
+		apiURL:        strings.TrimSuffix(apiURL, "/"),
+		webhookURL:    config.WebhookURL,
+		enableReplies: config.EnableReplies,
+		templates:     templates,
+	}, nil
+}
+
+// String returns the name of the notifier
+func (n *SlackNotifier) String() string {
+	return "slack"
+}
+
+// Notify sends a notification when a product becomes available or is sold out again
+// implements the Notifier interface
+func (n *SlackNotifier) Notify(event *RestockEvent) error {
+	switch event.Type {
+	case EventAvailable:
+		return n.notifyWhenAvailable(event)
+	case EventNotAvailable:
+		return n.notifyWhenNotAvailable(event)
+	}
+	return nil
+}
+
+// notifyWhenAvailable create a Slack message for announcing that a product is available
+func (n *SlackNotifier) notifyWhenAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+	text, err := n.templates.Available(NewMessageData(event))
+	if err != nil {
+		return err
+	}
+
+	if n.token == "" {
+		return n.sendWebhook(text)
+	}
+
+	response, err := n.postMessage(text, "")
+	if err != nil {
+		return err
+	}
+
+	// save slack message to database
+	m := SlackMessage{TS: response.TS, Channel: response.Channel, ProductURL: productURL}
+	trx := n.db.Create(&m)
+	if trx.Error != nil {
+		return fmt.Errorf("failed to save slack message %s to database: %s", m.TS, trx.Error)
+	}
+	log.Debugf("slack message %s saved to database", m.TS)
+
+	return nil
+}
+
+// notifyWhenNotAvailable create a Slack message in the thread of the notifyWhenAvailable message to say it's gone
+func (n *SlackNotifier) notifyWhenNotAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+	if n.token == "" {
+		if !n.enableReplies {
+			return nil
+		}
+		// messages of the webhook are not threaded so the URL is added to the reply
+		text, err := n.templates.NotAvailable(NewMessageData(event))
+		if err != nil {
+			return err
+		}
+		return n.sendWebhook(fmt.Sprintf("%s: %s", text, productURL))
+	}
+
+	// find message in the database
+	var m SlackMessage
+	trx := n.db.Where(SlackMessage{ProductURL: productURL}).First(&m)
+	if trx.Error == gorm.ErrRecordNotFound {
+		// product was already available before the notifier was enabled
+		log.Debugf("slack message for product with url %s not found, skipping not available notification", productURL)
+		return nil
+	}
+	if trx.Error != nil {
+		return fmt.Errorf("failed to find slack message in database for product with url %s: %s", productURL, trx.Error)
+	}
+
+	if n.enableReplies {
+		text, err := n.templates.NotAvailable(NewMessageData(event))
+		if err != nil {
+			return err
+		}
+		if _, err := n.postMessage(text, m.TS); err != nil {
+			return fmt.Errorf("failed to reply on slack: %s", err)
+		}
+		log.Infof("reply to slack message %s sent", m.TS)
+	}
+
+	// remove message from database
+	trx = n.db.Unscoped().Delete(&m)
+	if trx.Error != nil {
+		return fmt.Errorf("failed to remove message %s from database: %s", m.TS, trx.Error)
+	}
+	log.Debugf("slack message removed from database")
+	return nil
+}
+
+// postMessage sends a message with the Web API, in a thread when threadTS is not empty
+func (n *SlackNotifier) postMessage(text string, threadTS string) (*slackResponse, error) {
+	message := slackMessage{Channel: n.channel, Text: text, ThreadTS: threadTS}
+	content, err := n.post(n.apiURL+"/chat.postMessage", message, n.token)
+	if err != nil {
+		return nil, err
+	}
+
+	var response slackResponse
+	if err = json.Unmarshal(content, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode slack response: %s", err)
+	}
+	if !response.OK {
+		return nil, fmt.Errorf("slack returned error %s", response.Error)
+	}
+	log.Infof("message %s sent to slack", response.TS)
+	return &response, nil
+}
+
+// sendWebhook sends a message to the incoming webhook
+func (n *SlackNotifier) sendWebhook(text string) error {
+	if _, err := n.post(n.webhookURL, slackMessage{Text: text}, ""); err != nil {
+		return err
+	}
+	log.Infof("message sent to slack webhook")
+	return nil
+}
+
+// post a JSON message and return the response body
+func (n *SlackNotifier) post(url string, message slackMessage, token string) ([]byte, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	log.Debugf("sending message %s to slack", body)
+	res, err := n.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("slack returned %d: %s", res.StatusCode, content)
+	}
+	return content, nil
+}
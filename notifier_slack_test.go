@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slackRequest stores a request received by the fake Slack server
+type slackRequest struct {
+	path          string
+	authorization string
+	message       slackMessage
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var requests []slackRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message slackMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("cannot decode message: %s", err)
+		}
+		requests = append(requests, slackRequest{path: r.URL.Path, authorization: r.Header.Get("Authorization"), message: message})
+		if r.URL.Path == "/webhook" {
+			fmt.Fprint(w, "ok")
+		} else {
+			fmt.Fprintf(w, `{"ok": true, "channel": "C1234", "ts": "1621684800.00010%d"}`, len(requests))
+		}
+	}))
+	defer server.Close()
+
+	db, product := newNotifierTestDatabase(t)
+
+	tests := []struct {
+		config   SlackConfig
+		expected []slackRequest // text of messages is compared by prefix
+	}{
+		{ // chat.postMessage with replies in thread
+			SlackConfig{Token: "xoxb-token", Channel: "#restock", APIURL: server.URL + "/api/", EnableReplies: true},
+			[]slackRequest{
+				{"/api/chat.postMessage", "Bearer xoxb-token", slackMessage{Channel: "#restock", Text: "*Name:* MSI GeForce RTX 3080 GAMING X\n*Retailer:* ldlc.com\n*Price:* 899.99€\n*URL:* <https://www.ldlc.com/fiche/PB00385720.html|go to website>"}},
+				{"/api/chat.postMessage", "Bearer xoxb-token", slackMessage{Channel: "#restock", Text: "And it's gone (1m30s)", ThreadTS: "1621684800.000101"}},
+			},
+		},
+		{ // chat.postMessage without replies
+			SlackConfig{Token: "xoxb-token", Channel: "#restock", APIURL: server.URL + "/api"},
+			[]slackRequest{
+				{"/api/chat.postMessage", "Bearer xoxb-token", slackMessage{Channel: "#restock", Text: "*Name:*"}},
+			},
+		},
+		{ // incoming webhook
+			SlackConfig{WebhookURL: server.URL + "/webhook", EnableReplies: true},
+			[]slackRequest{
+				{"/webhook", "", slackMessage{Text: "*Name:*"}},
+				{"/webhook", "", slackMessage{Text: "And it's gone (1m30s): https://www.ldlc.com/fiche/PB00385720.html"}},
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestSlackNotifier#%d", i), func(t *testing.T) {
+			requests = nil
+			notifier, err := NewSlackNotifier(&tc.config, db)
+			if err != nil {
+				t.Fatalf("cannot create notifier: %s", err)
+			}
+			if err = notifier.Notify(newAvailableEvent(product)); err != nil {
+				t.Fatalf("cannot notify when available: %s", err)
+			}
+			if err = notifier.Notify(newNotAvailableEvent(product, 90*time.Second)); err != nil {
+				t.Fatalf("cannot notify when not available: %s", err)
+			}
+
+			if len(requests) != len(tc.expected) {
+				t.Fatalf("got %d requests, want %d", len(requests), len(tc.expected))
+			}
+			for j, expected := range tc.expected {
+				got := requests[j]
+				if got.path != expected.path || got.authorization != expected.authorization || got.message.Channel != expected.message.Channel || got.message.ThreadTS != expected.message.ThreadTS || !strings.HasPrefix(got.message.Text, expected.message.Text) {
+					t.Errorf("got %+v, want %+v", got, expected)
+				} else {
+					t.Logf("got %+v", got)
+				}
+			}
+		})
+	}
+}
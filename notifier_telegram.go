@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	telegram "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -12,24 +16,110 @@ import (
 // TelegramMessage to store relationship between a Product and a Telegram notification
 type TelegramMessage struct {
 	gorm.Model
-	MessageID  int `gorm:"not null;unique"`
+	ChatID     int64 `gorm:"not null;default:0;uniqueIndex:idx_telegram_messages_chat_message"` // 0 for the configured chat or channel
+	MessageID  int   `gorm:"not null;uniqueIndex:idx_telegram_messages_chat_message"`
 	ProductURL string
 	Product    Product `gorm:"not null;references:URL;constraint:OnDelete:CASCADE"`
+	NotifiedAt *time.Time
+	ClosedAt   *time.Time `gorm:"index"`
+}
+
+// DefaultTelegramMessagesPerMinute to stay below the limit of messages sent to a group
+const DefaultTelegramMessagesPerMinute = 20
+
+// number of attempts to send a message when Telegram asks to retry later
+const maxTelegramAttempts = 2
+
+// telegramLimiter to space out messages sent to Telegram
+type telegramLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	now      func() time.Time
+	sleep    func(time.Duration)
+}
+
+// newTelegramLimiter creates a telegramLimiter allowing a number of messages per minute
+func newTelegramLimiter(messagesPerMinute int) *telegramLimiter {
+	if messagesPerMinute <= 0 {
+		messagesPerMinute = DefaultTelegramMessagesPerMinute
+	}
+	return &telegramLimiter{
+		interval: time.Minute / time.Duration(messagesPerMinute),
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// Wait blocks until a message can be sent
+func (l *telegramLimiter) Wait() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if l.next.After(now) {
+		l.sleep(l.next.Sub(now))
+		now = l.next
+	}
+	l.next = now.Add(l.interval)
+}
+
+// Delay postpones the next message, when Telegram returns a retry_after parameter
+func (l *telegramLimiter) Delay(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if next := l.now().Add(d); next.After(l.next) {
+		l.next = next
+	}
+}
+
+// built-in templates of Telegram messages using markdown
+var telegramTemplates = map[string]messageTemplateTexts{
+	LanguageEnglish: {
+		available: `*Name:* {{.Name}}
+*Retailer:* {{.Shop}}
+*Price:* {{.FormattedPrice}}
+*URL*: [go to website]({{.URL}})
+*Date/Time:* {{.Date}}`,
+		notAvailable: "And it's gone ({{.Duration}})",
+		priceDrop:    "Price drop: {{.FormattedOldPrice}} → {{.FormattedPrice}}",
+	},
+	LanguageFrench: {
+		available: `*Nom :* {{.Name}}
+*Vendeur :* {{.Shop}}
+*Prix :* {{.FormattedPrice}}
+*URL :* [voir le site]({{.URL}})
+*Date/Heure :* {{.Date}}`,
+		notAvailable: "Et c'est fini ({{.Duration}})",
+		priceDrop:    "Baisse de prix : {{.FormattedOldPrice}} → {{.FormattedPrice}}",
+	},
 }
 
 // TelegramNotifier to manage notifications to Twitter
 type TelegramNotifier struct {
-	db            *gorm.DB
-	bot           *telegram.BotAPI
-	chatID        int64
-	channelName   string
-	enableReplies bool
+	db               *gorm.DB
+	bot              *telegram.BotAPI
+	chatID           int64
+	channelName      string
+	enableReplies    bool
+	cooldown         time.Duration
+	reuseWindow      time.Duration
+	limiter          *telegramLimiter
+	templates        *MessageTemplates
+	allowedChats     map[int64]bool
+	maxSubscriptions int
+	patterns         map[string]*regexp.Regexp
+	patternsMu       sync.Mutex
 }
 
 // NewTelegramNotifier to create a Notifier with Telegram capabilities
 func NewTelegramNotifier(config *TelegramConfig, db *gorm.DB) (*TelegramNotifier, error) {
-	// create table
-	err := db.AutoMigrate(&TelegramMessage{})
+	// create tables
+	err := migrateTelegram(db)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := NewMessageTemplates(config.Templates, telegramTemplates)
 	if err != nil {
 		return nil, err
 	}
@@ -41,36 +131,151 @@ func NewTelegramNotifier(config *TelegramConfig, db *gorm.DB) (*TelegramNotifier
 	}
 	log.Debugf("connected to telegram as %s", bot.Self.UserName)
 
+	allowedChats := make(map[int64]bool)
+	for _, chatID := range config.AllowedChats {
+		allowedChats[chatID] = true
+	}
+	maxSubscriptions := config.MaxSubscriptions
+	if maxSubscriptions <= 0 {
+		maxSubscriptions = DefaultTelegramMaxSubscriptions
+	}
+
 	return &TelegramNotifier{
-		db:            db,
-		bot:           bot,
-		chatID:        config.ChatID,
-		channelName:   config.ChannelName,
-		enableReplies: config.EnableReplies,
+		db:               db,
+		bot:              bot,
+		chatID:           config.ChatID,
+		channelName:      config.ChannelName,
+		enableReplies:    config.EnableReplies,
+		cooldown:         time.Duration(config.Cooldown) * time.Second,
+		reuseWindow:      time.Duration(config.ReuseWindow) * time.Second,
+		limiter:          newTelegramLimiter(config.MessagesPerMinute),
+		templates:        templates,
+		allowedChats:     allowedChats,
+		maxSubscriptions: maxSubscriptions,
+		patterns:         make(map[string]*regexp.Regexp),
 	}, nil
 }
 
-// NotifyWhenAvailable create a Telegram message for announcing that a product is available
+// migrateTelegram creates tables of the Telegram notifier
+// Previous versions created a unique index on the message identifier, which is only unique per chat
+func migrateTelegram(db *gorm.DB) error {
+	if err := db.AutoMigrate(&TelegramMessage{}, &TelegramSubscription{}); err != nil {
+		return err
+	}
+
+	migrator := db.Migrator()
+	switch db.Dialector.Name() {
+	case "mysql":
+		if migrator.HasIndex(&TelegramMessage{}, "message_id") {
+			return migrator.DropIndex(&TelegramMessage{}, "message_id")
+		}
+	case "postgres":
+		// the unique index is owned by a constraint
+		if migrator.HasConstraint(&TelegramMessage{}, "telegram_messages_message_id_key") {
+			return migrator.DropConstraint(&TelegramMessage{}, "telegram_messages_message_id_key")
+		}
+	case "sqlite":
+		// the table is rebuilt without the unique constraint by AutoMigrate
+		if migrator.HasIndex(&TelegramMessage{}, "sqlite_autoindex_telegram_messages_1") {
+			return fmt.Errorf("cannot drop unique index on message_id of telegram_messages")
+		}
+	}
+	return nil
+}
+
+// String returns the name of the notifier
+func (n *TelegramNotifier) String() string {
+	return "telegram"
+}
+
+// Notify sends a notification when a product becomes available or is sold out again
 // implements the Notifier interface
-func (n *TelegramNotifier) NotifyWhenAvailable(shopName string, productName string, productPrice float64, productCurrency string, productURL string) error {
-	// TODO: check if message exists in the database to avoid flood
+func (n *TelegramNotifier) Notify(event *RestockEvent) error {
+	switch event.Type {
+	case EventAvailable:
+		return n.notifyWhenAvailable(event)
+	case EventNotAvailable:
+		return n.notifyWhenNotAvailable(event)
+	case EventPriceDrop:
+		return n.notifyWhenPriceDrop(event)
+	}
+	return nil
+}
+
+// notifyWhenAvailable create a Telegram message for announcing that a product is available
+// The message is sent to the configured chat or channel and to chats subscribed to the product
+func (n *TelegramNotifier) notifyWhenAvailable(event *RestockEvent) error {
+	chatIDs, err := n.findChats(event.Product.Name)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, chatID := range chatIDs {
+		if err = n.announce(event, chatID); err != nil {
+			log.Warnf("%s", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// announce a product available to a chat
+func (n *TelegramNotifier) announce(event *RestockEvent, chatID int64) error {
+	productURL := event.Product.URL
+	now := time.Now()
+
+	// check if message exists in the database to avoid flood
+	var m TelegramMessage
+	trx := n.db.Where("product_url = ? AND chat_id = ?", productURL, chatID).Order("created_at desc").First(&m)
+	if trx.Error != nil && trx.Error != gorm.ErrRecordNotFound {
+		return fmt.Errorf("failed to find telegram message in database for product with url %s: %s", productURL, trx.Error)
+	}
+	found := trx.Error == nil
+	if found {
+		if m.ClosedAt == nil {
+			log.Debugf("telegram message %d for product with url %s is still open, skipping available notification", m.MessageID, productURL)
+			return nil
+		}
+
+		// product flapping, reopen the previous message instead of sending a new one
+		if n.reuseWindow > 0 && now.Sub(*m.ClosedAt) < n.reuseWindow {
+			m.ClosedAt = nil
+			if trx = n.db.Save(&m); trx.Error != nil {
+				return fmt.Errorf("failed to reopen telegram message %d: %s", m.MessageID, trx.Error)
+			}
+			log.Infof("telegram message %d reused for product with url %s", m.MessageID, productURL)
+			return nil
+		}
+
+		if n.inCooldown(&m, now) {
+			log.Infof("cooldown of product with url %s not expired, skipping telegram available notification", productURL)
+			return nil
+		}
+	}
 
 	// send message to telegram
-	formattedPrice := formatPrice(productPrice, productCurrency)
-	rawMessage := `*Name:* %s
-*Retailer:* %s
-*Price:* %s
-*URL*: [go to website](%s)
-*Date/Time:* %s`
-	message := fmt.Sprintf(rawMessage, productName, shopName, formattedPrice, productURL, time.Now().UTC().Format("2006-01-02 15:04:05 (-0700)"))
-	messageID, err := n.sendMessage(message, 0)
+	message, err := n.templates.Available(NewMessageData(event))
 	if err != nil {
 		return err
 	}
+	messageID, err := n.sendMessage(chatID, message, 0)
+	if err != nil {
+		if n.removeUnreachableChat(chatID, err) {
+			return nil
+		}
+		return err
+	}
+
+	// replace the closed message by the new one
+	if found {
+		if trx = n.db.Unscoped().Delete(&m); trx.Error != nil {
+			log.Warnf("failed to remove telegram message %d from database: %s", m.MessageID, trx.Error)
+		}
+	}
 
 	// save telegram message to database
-	m := TelegramMessage{MessageID: messageID, ProductURL: productURL}
-	trx := n.db.Create(&m)
+	m = TelegramMessage{ChatID: chatID, MessageID: messageID, ProductURL: productURL, NotifiedAt: &now}
+	trx = n.db.Create(&m)
 	if trx.Error != nil {
 		return fmt.Errorf("failed to save telegram message %d to database: %s", m.MessageID, trx.Error)
 	}
@@ -79,34 +284,67 @@ func (n *TelegramNotifier) NotifyWhenAvailable(shopName string, productName stri
 	return nil
 }
 
-// NotifyWhenNotAvailable create a Telegram message replying to the NotifyWhenAvailable message to say it's gone
-// implements the Notifier interface
-func (n *TelegramNotifier) NotifyWhenNotAvailable(productURL string, duration time.Duration) error {
-	// find message in the database
-	var m TelegramMessage
-	trx := n.db.Where(TelegramMessage{ProductURL: productURL}).First(&m)
-	if trx.Error != nil {
-		return fmt.Errorf("failed to find telegram message in database for product with url %s: %s", productURL, trx.Error)
+// notifyWhenNotAvailable create Telegram messages replying to the notifyWhenAvailable messages to say it's gone
+func (n *TelegramNotifier) notifyWhenNotAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+
+	// find messages in the database
+	messages, err := n.findOpenMessages(productURL)
+	if err != nil {
+		return err
 	}
-	if m.MessageID == 0 {
+	if len(messages) == 0 {
 		log.Warnf("telegram message for product with url %s not found, skipping close notification", productURL)
 		return nil
 	}
 
-	if n.enableReplies {
+	var lastErr error
+	for i := range messages {
+		if err = n.close(event, &messages[i]); err != nil {
+			log.Warnf("%s", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// close the message of a product not available anymore
+func (n *TelegramNotifier) close(event *RestockEvent, m *TelegramMessage) error {
+	now := time.Now()
+
+	if n.enableReplies && n.inCooldown(m, now) {
+		log.Infof("cooldown of product with url %s not expired, skipping telegram reply", m.ProductURL)
+	} else if n.enableReplies {
 		// format message
-		text := fmt.Sprintf("And it's gone (%s)", duration)
+		text, err := n.templates.NotAvailable(NewMessageData(event))
+		if err != nil {
+			return err
+		}
 
 		// send reply on telegram
-		_, err := n.sendMessage(text, m.MessageID)
+		_, err = n.sendMessage(m.ChatID, text, m.MessageID)
 		if err != nil {
+			if n.removeUnreachableChat(m.ChatID, err) {
+				return nil
+			}
 			return fmt.Errorf("failed to reply on telegram: %s", err)
 		}
+		m.NotifiedAt = &now
 		log.Infof("reply to telegram message %d sent", m.MessageID)
 	}
 
+	// keep the closed message to reuse it if the product is available again soon and to honor the cooldown
+	if n.reuseWindow > 0 || n.cooldown > 0 {
+		m.ClosedAt = &now
+		if trx := n.db.Save(m); trx.Error != nil {
+			return fmt.Errorf("failed to close telegram message %d: %s", m.MessageID, trx.Error)
+		}
+		log.Debugf("telegram message %d closed", m.MessageID)
+		return nil
+	}
+
 	// remove message from database
-	trx = n.db.Unscoped().Delete(&m)
+	trx := n.db.Unscoped().Delete(m)
 	if trx.Error != nil {
 		return fmt.Errorf("failed to remove message %d from database: %s", m.MessageID, trx.Error)
 	}
@@ -114,10 +352,123 @@ func (n *TelegramNotifier) NotifyWhenNotAvailable(productURL string, duration ti
 	return nil
 }
 
-func (n *TelegramNotifier) sendMessage(text string, reply int) (int, error) {
+// notifyWhenPriceDrop create Telegram messages replying to the notifyWhenAvailable messages with the old and new prices
+func (n *TelegramNotifier) notifyWhenPriceDrop(event *RestockEvent) error {
+	productURL := event.Product.URL
+	now := time.Now()
+
+	// find messages in the database
+	messages, err := n.findOpenMessages(productURL)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		log.Debugf("telegram message for product with url %s not found, skipping price drop notification", productURL)
+		return nil
+	}
+
+	text, err := n.templates.PriceDrop(NewMessageData(event))
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, m := range messages {
+		if n.inCooldown(&m, now) {
+			log.Infof("cooldown of product with url %s not expired, skipping telegram price drop notification", productURL)
+			continue
+		}
+		if _, err = n.sendMessage(m.ChatID, text, m.MessageID); err != nil {
+			if n.removeUnreachableChat(m.ChatID, err) {
+				continue
+			}
+			lastErr = fmt.Errorf("failed to reply on telegram: %s", err)
+			log.Warnf("%s", lastErr)
+			continue
+		}
+		log.Infof("price drop reply to telegram message %d sent", m.MessageID)
+
+		m.NotifiedAt = &now
+		if trx := n.db.Save(&m); trx.Error != nil {
+			log.Warnf("failed to save telegram message %d to database: %s", m.MessageID, trx.Error)
+		}
+	}
+	return lastErr
+}
+
+// removeUnreachableChat removes subscriptions and messages of a chat which can't receive messages anymore
+// Returns false for the configured chat or channel and for temporary errors
+func (n *TelegramNotifier) removeUnreachableChat(chatID int64, err error) bool {
+	if chatID == 0 || !isPermanentTelegramError(err) {
+		return false
+	}
+	log.Warnf("telegram chat %d is unreachable, removing its subscriptions: %s", chatID, err)
+	if trx := n.db.Unscoped().Where("chat_id = ?", chatID).Delete(&TelegramSubscription{}); trx.Error != nil {
+		log.Warnf("failed to remove telegram subscriptions of chat %d: %s", chatID, trx.Error)
+	}
+	if trx := n.db.Unscoped().Where("chat_id = ?", chatID).Delete(&TelegramMessage{}); trx.Error != nil {
+		log.Warnf("failed to remove telegram messages of chat %d: %s", chatID, trx.Error)
+	}
+	return true
+}
+
+// isPermanentTelegramError returns true when a chat has blocked the bot or doesn't exist anymore
+func isPermanentTelegramError(err error) bool {
+	var telegramErr telegram.Error
+	if !errors.As(err, &telegramErr) {
+		return false
+	}
+	return strings.HasPrefix(telegramErr.Message, "Forbidden") || strings.Contains(telegramErr.Message, "chat not found")
+}
+
+// findChats returns chats to notify about a product, 0 being the configured chat or channel
+func (n *TelegramNotifier) findChats(productName string) ([]int64, error) {
+	var chatIDs []int64
+	if n.chatID != 0 || n.channelName != "" {
+		chatIDs = append(chatIDs, 0)
+	}
+
+	var subscriptions []TelegramSubscription
+	trx := n.db.Order("chat_id asc, id asc").Find(&subscriptions)
+	if trx.Error != nil {
+		return nil, fmt.Errorf("failed to find telegram subscriptions in database: %s", trx.Error)
+	}
+	subscribed := make(map[int64]bool)
+	for _, s := range subscriptions {
+		// the configured chat is already notified and chats not allowed are ignored
+		if subscribed[s.ChatID] || (n.chatID != 0 && s.ChatID == n.chatID) || !n.allowed(s.ChatID) {
+			continue
+		}
+		if n.match(s.Pattern, productName) {
+			subscribed[s.ChatID] = true
+			chatIDs = append(chatIDs, s.ChatID)
+		}
+	}
+	return chatIDs, nil
+}
+
+// findOpenMessages returns messages announcing a product still available in all chats
+func (n *TelegramNotifier) findOpenMessages(productURL string) ([]TelegramMessage, error) {
+	var messages []TelegramMessage
+	trx := n.db.Where(TelegramMessage{ProductURL: productURL}).Where("closed_at IS NULL").Order("id asc").Find(&messages)
+	if trx.Error != nil {
+		return nil, fmt.Errorf("failed to find telegram message in database for product with url %s: %s", productURL, trx.Error)
+	}
+	return messages, nil
+}
+
+// inCooldown returns true when a message about the product has been sent less than cooldown ago
+func (n *TelegramNotifier) inCooldown(m *TelegramMessage, now time.Time) bool {
+	return n.cooldown > 0 && m.NotifiedAt != nil && now.Sub(*m.NotifiedAt) < n.cooldown
+}
+
+// sendMessage sends a markdown message to a chat, 0 being the configured chat or channel
+func (n *TelegramNotifier) sendMessage(chatID int64, text string, reply int) (int, error) {
 	log.Debugf("sending message %s to telegram", text)
 	var request telegram.MessageConfig
-	if n.chatID != 0 {
+	if chatID != 0 {
+		request = telegram.NewMessage(chatID, text)
+	} else if n.chatID != 0 {
 		request = telegram.NewMessage(n.chatID, text)
 	} else {
 		request = telegram.NewMessageToChannel(n.channelName, text)
@@ -128,11 +479,29 @@ func (n *TelegramNotifier) sendMessage(text string, reply int) (int, error) {
 	if reply != 0 {
 		request.ReplyToMessageID = reply
 	}
+	return n.send(request)
+}
 
-	response, err := n.bot.Send(request)
-	if err != nil {
+// send a message when the limiter allows it
+func (n *TelegramNotifier) send(request telegram.MessageConfig) (int, error) {
+	for attempt := 1; ; attempt++ {
+		n.limiter.Wait()
+		response, err := n.bot.Send(request)
+		if err == nil {
+			log.Infof("message %d sent to telegram", response.MessageID)
+			return response.MessageID, nil
+		}
+
+		// honor the delay requested by telegram when flood limits are reached
+		var telegramErr telegram.Error
+		if errors.As(err, &telegramErr) && telegramErr.RetryAfter > 0 {
+			retryAfter := time.Duration(telegramErr.RetryAfter) * time.Second
+			n.limiter.Delay(retryAfter)
+			if attempt < maxTelegramAttempts {
+				log.Warnf("telegram flood limit reached, retrying in %s", retryAfter)
+				continue
+			}
+		}
 		return 0, err
 	}
-	log.Infof("message %d sent to telegram", response.MessageID)
-	return response.MessageID, nil
 }
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	telegram "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maximum number of products listed by the /available command
+const maxTelegramAvailableProducts = 20
+
+// DefaultTelegramMaxSubscriptions to limit the number of patterns followed by a chat
+const DefaultTelegramMaxSubscriptions = 10
+
+// help message of the Telegram bot
+const telegramBotHelp = `Get notified when products are available:
+/subscribe <pattern> - notify products matching the pattern (ex: /subscribe rtx 3080)
+/unsubscribe [pattern] - remove one or all subscriptions
+/list - list subscriptions
+/shops - list monitored shops
+/available [pattern] - list available products`
+
+// TelegramSubscription to store patterns of product names followed by a chat
+type TelegramSubscription struct {
+	gorm.Model
+	ChatID  int64  `gorm:"not null;uniqueIndex:idx_telegram_subscriptions_chat_pattern"`
+	Pattern string `gorm:"not null;uniqueIndex:idx_telegram_subscriptions_chat_pattern"`
+}
+
+// allowed returns true when a chat can send commands to the bot
+// All chats are allowed unless allowed chats have been configured
+func (n *TelegramNotifier) allowed(chatID int64) bool {
+	return len(n.allowedChats) == 0 || (n.chatID != 0 && chatID == n.chatID) || n.allowedChats[chatID]
+}
+
+// compile a subscription pattern, case insensitive
+// Patterns are compiled once to be matched against every product
+func (n *TelegramNotifier) compile(pattern string) (*regexp.Regexp, error) {
+	n.patternsMu.Lock()
+	defer n.patternsMu.Unlock()
+	if regex, ok := n.patterns[pattern]; ok {
+		return regex, nil
+	}
+	regex, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	n.patterns[pattern] = regex
+	return regex, nil
+}
+
+// match returns true when the product name matches a subscription pattern
+func (n *TelegramNotifier) match(pattern string, productName string) bool {
+	regex, err := n.compile(pattern)
+	if err != nil {
+		log.Warnf("cannot match telegram subscription pattern %s: %s", pattern, err)
+		return false
+	}
+	return regex.MatchString(productName)
+}
+
+// Listen handles commands sent to the Telegram bot until the context is done
+func (n *TelegramNotifier) Listen(ctx context.Context) {
+	config := telegram.NewUpdate(0)
+	config.Timeout = 60
+	updates := n.bot.GetUpdatesChan(config)
+	defer n.bot.StopReceivingUpdates()
+	log.Infof("telegram bot %s listening for commands", n.bot.Self.UserName)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-updates:
+			if update.Message == nil || !update.Message.IsCommand() {
+				continue
+			}
+			chatID := update.Message.Chat.ID
+			command := update.Message.Command()
+			if !n.allowed(chatID) {
+				log.Warnf("telegram command /%s from chat %d rejected, chat not allowed", command, chatID)
+				continue
+			}
+			log.Debugf("telegram command /%s received from chat %d", command, chatID)
+
+			request := telegram.NewMessage(chatID, n.handleCommand(chatID, command, strings.TrimSpace(update.Message.CommandArguments())))
+			request.DisableWebPagePreview = true
+			if _, err := n.send(request); err != nil {
+				log.Warnf("cannot reply to telegram command /%s: %s", command, err)
+			}
+		}
+	}
+}
+
+// handleCommand runs a command sent by a chat and returns the text of the reply
+func (n *TelegramNotifier) handleCommand(chatID int64, command string, arguments string) string {
+	switch command {
+	case "start", "help":
+		return telegramBotHelp
+	case "subscribe":
+		return n.subscribe(chatID, arguments)
+	case "unsubscribe":
+		return n.unsubscribe(chatID, arguments)
+	case "list":
+		return n.listSubscriptions(chatID)
+	case "shops":
+		return n.listShops()
+	case "available":
+		return n.listAvailableProducts(arguments)
+	}
+	return fmt.Sprintf("Unknown command /%s, send /help to list commands", command)
+}
+
+// subscribe a chat to products matching a pattern
+func (n *TelegramNotifier) subscribe(chatID int64, pattern string) string {
+	if pattern == "" {
+		return "Usage: /subscribe <pattern>"
+	}
+	if _, err := n.compile(pattern); err != nil {
+		return fmt.Sprintf("Invalid pattern: %s", err)
+	}
+
+	var count int64
+	if trx := n.db.Model(&TelegramSubscription{}).Where("chat_id = ? AND pattern <> ?", chatID, pattern).Count(&count); trx.Error != nil {
+		log.Warnf("cannot count telegram subscriptions of chat %d: %s", chatID, trx.Error)
+		return "Cannot save subscription, please try again later"
+	}
+	if count >= int64(n.maxSubscriptions) {
+		return fmt.Sprintf("Too many subscriptions (maximum %d), send /unsubscribe to remove some", n.maxSubscriptions)
+	}
+
+	var s TelegramSubscription
+	trx := n.db.Where("chat_id = ? AND pattern = ?", chatID, pattern).Attrs(TelegramSubscription{ChatID: chatID, Pattern: pattern}).FirstOrCreate(&s)
+	if trx.Error != nil {
+		log.Warnf("cannot save telegram subscription of chat %d: %s", chatID, trx.Error)
+		return "Cannot save subscription, please try again later"
+	}
+	log.Infof("telegram chat %d subscribed to %s", chatID, pattern)
+	return fmt.Sprintf("Subscribed to products matching \"%s\"", pattern)
+}
+
+// unsubscribe a chat from a pattern or from all patterns
+func (n *TelegramNotifier) unsubscribe(chatID int64, pattern string) string {
+	trx := n.db.Unscoped().Where("chat_id = ?", chatID)
+	if pattern != "" {
+		trx = trx.Where("pattern = ?", pattern)
+	}
+	if trx = trx.Delete(&TelegramSubscription{}); trx.Error != nil {
+		log.Warnf("cannot remove telegram subscriptions of chat %d: %s", chatID, trx.Error)
+		return "Cannot remove subscriptions, please try again later"
+	}
+	if trx.RowsAffected == 0 {
+		return "No subscription found"
+	}
+	log.Infof("telegram chat %d unsubscribed from %d pattern(s)", chatID, trx.RowsAffected)
+	return fmt.Sprintf("Unsubscribed from %d pattern(s)", trx.RowsAffected)
+}
+
+// listSubscriptions returns patterns followed by a chat
+func (n *TelegramNotifier) listSubscriptions(chatID int64) string {
+	var subscriptions []TelegramSubscription
+	if trx := n.db.Where("chat_id = ?", chatID).Order("pattern asc").Find(&subscriptions); trx.Error != nil {
+		log.Warnf("cannot find telegram subscriptions of chat %d: %s", chatID, trx.Error)
+		return "Cannot list subscriptions, please try again later"
+	}
+	if len(subscriptions) == 0 {
+		return "No subscription, send /subscribe <pattern> to get notified"
+	}
+	lines := []string{"Subscriptions:"}
+	for _, s := range subscriptions {
+		lines = append(lines, "- "+s.Pattern)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// listShops returns names of monitored shops
+func (n *TelegramNotifier) listShops() string {
+	var shops []Shop
+	if trx := n.db.Order("name asc").Find(&shops); trx.Error != nil {
+		log.Warnf("cannot find shops: %s", trx.Error)
+		return "Cannot list shops, please try again later"
+	}
+	if len(shops) == 0 {
+		return "No shop"
+	}
+	lines := []string{"Shops:"}
+	for _, shop := range shops {
+		lines = append(lines, "- "+shop.Name)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// listAvailableProducts returns available products, eventually matching a pattern
+func (n *TelegramNotifier) listAvailableProducts(pattern string) string {
+	var products []Product
+	if trx := n.db.Preload("Shop").Where(map[string]interface{}{"available": true}).Order("name asc").Find(&products); trx.Error != nil {
+		log.Warnf("cannot find available products: %s", trx.Error)
+		return "Cannot list available products, please try again later"
+	}
+
+	var lines []string
+	count := 0
+	for _, product := range products {
+		if pattern != "" && !n.match(pattern, product.Name) {
+			continue
+		}
+		count++
+		if count <= maxTelegramAvailableProducts {
+			lines = append(lines, fmt.Sprintf("- %s (%s, %s)\n  %s", product.Name, product.Shop.Name, formatPrice(product.Price, product.PriceCurrency), product.URL))
+		}
+	}
+	if count == 0 {
+		return "No product available"
+	}
+	if count > maxTelegramAvailableProducts {
+		lines = append(lines, fmt.Sprintf("and %d more", count-maxTelegramAvailableProducts))
+	}
+	return "Available products:\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTelegramNotifierMatch(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	db, _ := newNotifierTestDatabase(t)
+	notifier, _ := newTestTelegramNotifier(t, server, TelegramConfig{}, db)
+
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"3080", "MSI GeForce RTX 3080 GAMING X", true},
+		{"rtx 3080", "MSI GeForce RTX 3080 GAMING X", true}, // case insensitive
+		{"rtx 30(70|80)", "MSI GeForce RTX 3070 GAMING X", true},
+		{"rx 6800", "MSI GeForce RTX 3080 GAMING X", false},
+		{"(", "MSI GeForce RTX 3080 GAMING X", false},    // invalid pattern
+		{"3080", "MSI GeForce RTX 3070 GAMING X", false}, // compiled pattern reused
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestTelegramNotifierMatch#%d", i), func(t *testing.T) {
+			got := notifier.match(tc.pattern, tc.name)
+			if got != tc.expected {
+				t.Errorf("for pattern %s and name %s, got %t, want %t", tc.pattern, tc.name, got, tc.expected)
+			} else {
+				t.Logf("for pattern %s and name %s, got %t", tc.pattern, tc.name, got)
+			}
+		})
+	}
+	if len(notifier.patterns) != 4 {
+		t.Errorf("got %d compiled patterns, want 4", len(notifier.patterns))
+	}
+}
+
+func TestTelegramNotifierAllowed(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	db, _ := newNotifierTestDatabase(t)
+
+	tests := []struct {
+		allowedChats []int64
+		chatID       int64
+		expected     bool
+	}{
+		{nil, 43, true},           // all chats allowed by default
+		{[]int64{42}, 1234, true}, // configured chat
+		{[]int64{42}, 42, true},
+		{[]int64{42}, 43, false},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestTelegramNotifierAllowed#%d", i), func(t *testing.T) {
+			notifier, _ := newTestTelegramNotifier(t, server, TelegramConfig{AllowedChats: tc.allowedChats}, db)
+			got := notifier.allowed(tc.chatID)
+			if got != tc.expected {
+				t.Errorf("for chat %d and allowed chats %v, got %t, want %t", tc.chatID, tc.allowedChats, got, tc.expected)
+			} else {
+				t.Logf("for chat %d and allowed chats %v, got %t", tc.chatID, tc.allowedChats, got)
+			}
+		})
+	}
+}
+
+func TestTelegramHandleCommand(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	db, _ := newNotifierTestDatabase(t)
+	notifier, _ := newTestTelegramNotifier(t, server, TelegramConfig{MaxSubscriptions: 2}, db)
+
+	tests := []struct {
+		chatID    int64
+		command   string
+		arguments string
+		expected  string // compared by prefix
+	}{
+		{42, "start", "", "Get notified when products are available"},
+		{42, "list", "", "No subscription"},
+		{42, "subscribe", "", "Usage: /subscribe <pattern>"},
+		{42, "subscribe", "rtx (", "Invalid pattern"},
+		{42, "subscribe", "rtx 3080", "Subscribed to products matching \"rtx 3080\""},
+		{42, "subscribe", "rtx 3080", "Subscribed to products matching \"rtx 3080\""}, // already subscribed
+		{42, "subscribe", "rx 6800", "Subscribed to products matching \"rx 6800\""},
+		{42, "subscribe", "rx 6900", "Too many subscriptions (maximum 2)"},
+		{42, "subscribe", "rx 6800", "Subscribed to products matching \"rx 6800\""}, // already subscribed
+		{43, "subscribe", "rtx 3070", "Subscribed to products matching \"rtx 3070\""},
+		{42, "list", "", "Subscriptions:\n- rtx 3080\n- rx 6800"},
+		{42, "unsubscribe", "rtx 3070", "No subscription found"}, // subscription of another chat
+		{42, "unsubscribe", "rx 6800", "Unsubscribed from 1 pattern(s)"},
+		{42, "unsubscribe", "", "Unsubscribed from 1 pattern(s)"},
+		{42, "list", "", "No subscription"},
+		{43, "list", "", "Subscriptions:\n- rtx 3070"},
+		{42, "shops", "", "Shops:\n- ldlc.com"},
+		{42, "available", "", "Available products:\n- MSI GeForce RTX 3080 GAMING X (ldlc.com, 899.99€)\n  https://www.ldlc.com/fiche/PB00385720.html"},
+		{42, "available", "rx 6800", "No product available"},
+		{42, "unknown", "", "Unknown command /unknown"},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestTelegramHandleCommand#%d", i), func(t *testing.T) {
+			got := notifier.handleCommand(tc.chatID, tc.command, tc.arguments)
+			if !strings.HasPrefix(got, tc.expected) {
+				t.Errorf("for /%s %s in chat %d, got '%s', want '%s'", tc.command, tc.arguments, tc.chatID, got, tc.expected)
+			} else {
+				t.Logf("for /%s %s in chat %d, got '%s'", tc.command, tc.arguments, tc.chatID, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	telegram "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gorm.io/gorm"
+)
+
+// telegramRequest stores a message received by the fake Telegram server
+type telegramRequest struct {
+	chatID  string
+	text    string
+	replyTo string
+}
+
+// telegramTransport sends requests of the Telegram client to a test server
+type telegramTransport struct {
+	serverURL *url.URL
+}
+
+func (t *telegramTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme = t.serverURL.Scheme
+	r.URL.Host = t.serverURL.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// newTestTelegramNotifier creates a TelegramNotifier sending messages to a test server without waiting
+func newTestTelegramNotifier(t *testing.T, server *httptest.Server, config TelegramConfig, db *gorm.DB) (*TelegramNotifier, *[]time.Duration) {
+	if err := migrateTelegram(db); err != nil {
+		t.Fatalf("cannot migrate database: %s", err)
+	}
+	serverURL, _ := url.Parse(server.URL)
+	var sleeps []time.Duration
+	limiter := newTelegramLimiter(config.MessagesPerMinute)
+	limiter.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	allowedChats := make(map[int64]bool)
+	for _, chatID := range config.AllowedChats {
+		allowedChats[chatID] = true
+	}
+	maxSubscriptions := config.MaxSubscriptions
+	if maxSubscriptions <= 0 {
+		maxSubscriptions = DefaultTelegramMaxSubscriptions
+	}
+	return &TelegramNotifier{
+		db:               db,
+		bot:              &telegram.BotAPI{Token: "token", Client: &http.Client{Transport: &telegramTransport{serverURL: serverURL}}},
+		chatID:           1234,
+		enableReplies:    config.EnableReplies,
+		cooldown:         time.Duration(config.Cooldown) * time.Second,
+		reuseWindow:      time.Duration(config.ReuseWindow) * time.Second,
+		limiter:          limiter,
+		templates:        mustMessageTemplates(telegramTemplates),
+		allowedChats:     allowedChats,
+		maxSubscriptions: maxSubscriptions,
+		patterns:         make(map[string]*regexp.Regexp),
+	}, &sleeps
+}
+
+// baselineTelegramMessage is the TelegramMessage model of previous versions, with a unique message identifier
+type baselineTelegramMessage struct {
+	gorm.Model
+	MessageID  int `gorm:"not null;unique"`
+	ProductURL string
+	Product    Product `gorm:"not null;references:URL;constraint:OnDelete:CASCADE"`
+}
+
+// TableName to share the table of TelegramMessage
+func (baselineTelegramMessage) TableName() string {
+	return "telegram_messages"
+}
+
+func TestMigrateTelegram(t *testing.T) {
+	db, product := newNotifierTestDatabase(t)
+	if err := db.AutoMigrate(&baselineTelegramMessage{}); err != nil {
+		t.Fatalf("cannot create baseline schema: %s", err)
+	}
+	if trx := db.Create(&baselineTelegramMessage{MessageID: 1, ProductURL: product.URL}); trx.Error != nil {
+		t.Fatalf("cannot create baseline message: %s", trx.Error)
+	}
+
+	if err := migrateTelegram(db); err != nil {
+		t.Fatalf("cannot migrate database: %s", err)
+	}
+
+	// messages of different chats share the same identifier
+	for _, chatID := range []int64{42, 43} {
+		if trx := db.Create(&TelegramMessage{ChatID: chatID, MessageID: 1, ProductURL: product.URL}); trx.Error != nil {
+			t.Errorf("cannot create message 1 of chat %d: %s", chatID, trx.Error)
+		}
+	}
+	if trx := db.Create(&TelegramMessage{ChatID: 42, MessageID: 1, ProductURL: product.URL}); trx.Error == nil {
+		t.Errorf("got no error, want an error for a duplicate message of the same chat")
+	}
+
+	var count int64
+	db.Model(&TelegramMessage{}).Where(map[string]interface{}{"chat_id": 0}).Count(&count)
+	if count != 1 {
+		t.Errorf("got %d messages of the configured chat, want the baseline message", count)
+	}
+}
+
+func TestTelegramLimiter(t *testing.T) {
+	now := time.Now()
+	var sleeps []time.Duration
+	limiter := newTelegramLimiter(20)
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+	}
+
+	limiter.Wait()
+	limiter.Wait()
+	limiter.Delay(10 * time.Second)
+	limiter.Wait()
+
+	expected := []time.Duration{3 * time.Second, 10 * time.Second}
+	if !reflect.DeepEqual(sleeps, expected) {
+		t.Errorf("got sleeps %v, want %v", sleeps, expected)
+	}
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	var requests []telegramRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/sendMessage") {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		requests = append(requests, telegramRequest{chatID: r.FormValue("chat_id"), text: r.FormValue("text"), replyTo: r.FormValue("reply_to_message_id")})
+		fmt.Fprintf(w, `{"ok": true, "result": {"message_id": %d}}`, len(requests))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		config   TelegramConfig
+		events   []string
+		expected []telegramRequest // text of messages is compared by prefix
+	}{
+		{ // product announced once until it's gone
+			TelegramConfig{EnableReplies: true},
+			[]string{EventAvailable, EventAvailable, EventNotAvailable, EventAvailable},
+			[]telegramRequest{{"1234", "*Name:* MSI GeForce RTX 3080 GAMING X", ""}, {"1234", "And it's gone (1m30s)", "1"}, {"1234", "*Name:*", ""}},
+		},
+		{ // flapping product reuses the previous message
+			TelegramConfig{EnableReplies: true, ReuseWindow: 3600},
+			[]string{EventAvailable, EventNotAvailable, EventAvailable, EventNotAvailable},
+			[]telegramRequest{{"1234", "*Name:*", ""}, {"1234", "And it's gone", "1"}, {"1234", "And it's gone", "1"}},
+		},
+		{ // messages skipped during cooldown
+			TelegramConfig{EnableReplies: true, Cooldown: 3600},
+			[]string{EventAvailable, EventNotAvailable, EventAvailable, EventPriceDrop},
+			[]telegramRequest{{"1234", "*Name:*", ""}},
+		},
+		{ // price drop replies to the open message
+			TelegramConfig{},
+			[]string{EventAvailable, EventPriceDrop, EventNotAvailable, EventPriceDrop},
+			[]telegramRequest{{"1234", "*Name:*", ""}, {"1234", "Price drop: 899.99€ → 799.99€", "1"}},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestTelegramNotifier#%d", i), func(t *testing.T) {
+			requests = nil
+			db, product := newNotifierTestDatabase(t)
+			notifier, _ := newTestTelegramNotifier(t, server, tc.config, db)
+
+			for _, eventType := range tc.events {
+				var event *RestockEvent
+				switch eventType {
+				case EventAvailable:
+					event = newAvailableEvent(product)
+				case EventNotAvailable:
+					event = newNotAvailableEvent(product, 90*time.Second)
+				case EventPriceDrop:
+					event = newPriceDropEvent(product, 799.99)
+				}
+				if err := notifier.Notify(event); err != nil {
+					t.Fatalf("cannot notify %s event: %s", eventType, err)
+				}
+			}
+
+			if len(requests) != len(tc.expected) {
+				t.Fatalf("got %d requests %+v, want %d", len(requests), requests, len(tc.expected))
+			}
+			for j, expected := range tc.expected {
+				got := requests[j]
+				if got.chatID != expected.chatID || got.replyTo != expected.replyTo || !strings.HasPrefix(got.text, expected.text) {
+					t.Errorf("got %+v, want %+v", got, expected)
+				} else {
+					t.Logf("got %+v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestTelegramNotifierSubscriptions(t *testing.T) {
+	var requests []telegramRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, telegramRequest{chatID: r.FormValue("chat_id"), text: r.FormValue("text"), replyTo: r.FormValue("reply_to_message_id")})
+		fmt.Fprintf(w, `{"ok": true, "result": {"message_id": %d}}`, len(requests))
+	}))
+	defer server.Close()
+
+	db, product := newNotifierTestDatabase(t)
+	notifier, _ := newTestTelegramNotifier(t, server, TelegramConfig{EnableReplies: true, AllowedChats: []int64{42, 43}}, db)
+	for _, s := range []TelegramSubscription{{ChatID: 42, Pattern: "rtx 3080"}, {ChatID: 42, Pattern: "msi"}, {ChatID: 43, Pattern: "rx 6800"}, {ChatID: 1234, Pattern: "3080"}, {ChatID: 99, Pattern: "3080"}} {
+		db.Create(&s)
+	}
+
+	for _, event := range []*RestockEvent{newAvailableEvent(product), newPriceDropEvent(product, 799.99), newNotAvailableEvent(product, 90*time.Second)} {
+		if err := notifier.Notify(event); err != nil {
+			t.Fatalf("cannot notify %s event: %s", event.Type, err)
+		}
+	}
+
+	// configured chat and matching subscriptions of allowed chats are notified once
+	expected := []telegramRequest{
+		{"1234", "*Name:* MSI GeForce RTX 3080 GAMING X", ""},
+		{"42", "*Name:* MSI GeForce RTX 3080 GAMING X", ""},
+		{"1234", "Price drop", "1"},
+		{"42", "Price drop", "2"},
+		{"1234", "And it's gone", "1"},
+		{"42", "And it's gone", "2"},
+	}
+	if len(requests) != len(expected) {
+		t.Fatalf("got %d requests %+v, want %d", len(requests), requests, len(expected))
+	}
+	for i, e := range expected {
+		got := requests[i]
+		if got.chatID != e.chatID || got.replyTo != e.replyTo || !strings.HasPrefix(got.text, e.text) {
+			t.Errorf("got %+v, want %+v", got, e)
+		} else {
+			t.Logf("got %+v", got)
+		}
+	}
+}
+
+func TestTelegramNotifierUnreachableChat(t *testing.T) {
+	var requests []telegramRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, telegramRequest{chatID: r.FormValue("chat_id"), text: r.FormValue("text"), replyTo: r.FormValue("reply_to_message_id")})
+		switch r.FormValue("chat_id") {
+		case "42":
+			fmt.Fprint(w, `{"ok": false, "error_code": 403, "description": "Forbidden: bot was blocked by the user"}`)
+		case "43":
+			fmt.Fprint(w, `{"ok": false, "error_code": 400, "description": "Bad Request: chat not found"}`)
+		default:
+			fmt.Fprintf(w, `{"ok": true, "result": {"message_id": %d}}`, len(requests))
+		}
+	}))
+	defer server.Close()
+
+	db, product := newNotifierTestDatabase(t)
+	notifier, _ := newTestTelegramNotifier(t, server, TelegramConfig{EnableReplies: true, AllowedChats: []int64{42, 43, 44}}, db)
+	for _, s := range []TelegramSubscription{{ChatID: 42, Pattern: "3080"}, {ChatID: 43, Pattern: "3080"}, {ChatID: 44, Pattern: "3080"}} {
+		db.Create(&s)
+	}
+
+	// unreachable chats don't fail the delivery
+	if err := notifier.Notify(newAvailableEvent(product)); err != nil {
+		t.Fatalf("cannot notify when available: %s", err)
+	}
+	if len(requests) != 4 {
+		t.Errorf("got %d requests %+v, want 4", len(requests), requests)
+	}
+
+	var subscriptions []TelegramSubscription
+	db.Find(&subscriptions)
+	if len(subscriptions) != 1 || subscriptions[0].ChatID != 44 {
+		t.Errorf("got subscriptions %+v, want only the subscription of chat 44", subscriptions)
+	}
+
+	// errors of the configured chat are returned
+	db.Unscoped().Where("1 = 1").Delete(&TelegramMessage{})
+	notifier.chatID = 42
+	if err := notifier.Notify(newAvailableEvent(product)); err == nil {
+		t.Errorf("got no error, want an error for the configured chat")
+	}
+}
+
+func TestTelegramNotifierRetryAfter(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if count == 1 {
+			fmt.Fprint(w, `{"ok": false, "error_code": 429, "description": "Too Many Requests: retry after 5", "parameters": {"retry_after": 5}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"ok": true, "result": {"message_id": %d}}`, count)
+	}))
+	defer server.Close()
+
+	db, product := newNotifierTestDatabase(t)
+	notifier, sleeps := newTestTelegramNotifier(t, server, TelegramConfig{}, db)
+
+	if err := notifier.Notify(newAvailableEvent(product)); err != nil {
+		t.Fatalf("cannot notify when available: %s", err)
+	}
+	if count != 2 {
+		t.Errorf("got %d requests, want 2", count)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] <= 4*time.Second || (*sleeps)[0] > 5*time.Second {
+		t.Errorf("got sleeps %v, want a single sleep of 5s", *sleeps)
+	}
+}
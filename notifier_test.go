@@ -2,9 +2,48 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"gorm.io/gorm"
 )
 
+// newNotifierTestDatabase creates a database with an available product to notify
+func newNotifierTestDatabase(t *testing.T) (*gorm.DB, Product) {
+	db, err := NewDatabaseFromFile(filepath.Join(t.TempDir(), "restockbot.db"))
+	if err != nil {
+		t.Fatalf("cannot open database: %s", err)
+	}
+	if err = db.AutoMigrate(&Product{}, &Shop{}); err != nil {
+		t.Fatalf("cannot migrate database: %s", err)
+	}
+	shop := Shop{Name: "ldlc.com"}
+	db.Create(&shop)
+	product := Product{Name: "MSI GeForce RTX 3080 GAMING X", URL: "https://www.ldlc.com/fiche/PB00385720.html", Price: 899.99, PriceCurrency: "EUR", Available: true, ShopID: shop.ID, Shop: shop}
+	db.Create(&product)
+	return db, product
+}
+
+// newAvailableEvent creates an event of a product becoming available
+func newAvailableEvent(product Product) *RestockEvent {
+	return NewRestockEvent(EventAvailable, product.Shop, product, nil, 0)
+}
+
+// newNotAvailableEvent creates an event of a product not available anymore after duration
+func newNotAvailableEvent(product Product, duration time.Duration) *RestockEvent {
+	previous := product
+	product.Available = false
+	return NewRestockEvent(EventNotAvailable, product.Shop, product, &previous, duration)
+}
+
+// newPriceDropEvent creates an event of an available product with a lower price
+func newPriceDropEvent(product Product, price float64) *RestockEvent {
+	previous := product
+	product.Price = price
+	return NewRestockEvent(EventPriceDrop, product.Shop, product, &previous, 0)
+}
+
 func TestFormatPrice(t *testing.T) {
 	tests := []struct {
 		value    float64
@@ -28,3 +67,35 @@ func TestFormatPrice(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRestockEvent(t *testing.T) {
+	shop := Shop{Name: "shop.com"}
+	previous := &Product{Name: "my awesome product", URL: "https://shop.com/awesome", Price: 999.99, PriceCurrency: "EUR", Available: true}
+	product := *previous
+	product.Available = false
+
+	tests := []struct {
+		eventType string
+		previous  *Product
+	}{
+		{EventAvailable, nil}, // new product
+		{EventNotAvailable, previous},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestNewRestockEvent#%d", i), func(t *testing.T) {
+			got := NewRestockEvent(tc.eventType, shop, product, tc.previous, time.Minute)
+			if got.Type != tc.eventType || got.Product.Shop.Name != shop.Name || got.Duration != time.Minute || got.CreatedAt.IsZero() {
+				t.Errorf("got %+v, want %s event of a product of %s", got, tc.eventType, shop.Name)
+			}
+			if tc.previous == nil && got.Previous != nil {
+				t.Errorf("got previous state %+v, want nil", got.Previous)
+			}
+			if tc.previous != nil && (got.Previous == tc.previous || got.Previous.Shop.Name != shop.Name || !got.Previous.Available) {
+				t.Errorf("got previous state %+v, want a copy of %+v with shop %s", got.Previous, tc.previous, shop.Name)
+			} else {
+				t.Logf("got %+v", got)
+			}
+		})
+	}
+}
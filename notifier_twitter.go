@@ -1,14 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
-	"regexp"
+	"io/ioutil"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
-	"unicode/utf8"
 
-	"github.com/dghubble/go-twitter/twitter"
 	"github.com/dghubble/oauth1"
 	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -17,6 +19,9 @@ import (
 // maximum number of characters a tweet can support
 const tweetMaxSize = 280
 
+// DefaultTwitterAPIURL to call the Twitter API v2
+const DefaultTwitterAPIURL = "https://api.twitter.com"
+
 // Tweet to store relationship between a Product and a Twitter notification
 type Tweet struct {
 	gorm.Model
@@ -31,11 +36,37 @@ type Tweet struct {
 // TwitterNotifier to manage notifications to Twitter
 type TwitterNotifier struct {
 	db            *gorm.DB
-	client        *twitter.Client
-	user          *twitter.User
+	client        *http.Client
+	apiURL        string
+	user          *twitterUser
 	hashtagsMap   []map[string]string
 	enableReplies bool
 	retentionDays int
+	templates     *MessageTemplates
+}
+
+// twitterUser returned by the users/me endpoint
+type twitterUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// twitterTweet returned by the tweets endpoint
+type twitterTweet struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// twitterTweetRequest to create a tweet
+// See https://developer.twitter.com/en/docs/twitter-api/tweets/manage-tweets/api-reference/post-tweets
+type twitterTweetRequest struct {
+	Text  string             `json:"text"`
+	Reply *twitterTweetReply `json:"reply,omitempty"`
+}
+
+// twitterTweetReply to reply to a tweet
+type twitterTweetReply struct {
+	InReplyToTweetID string `json:"in_reply_to_tweet_id"`
 }
 
 // NewTwitterNotifier creates a TwitterNotifier
@@ -46,31 +77,41 @@ func NewTwitterNotifier(c *TwitterConfig, db *gorm.DB) (*TwitterNotifier, error)
 		return nil, err
 	}
 
+	templates, err := NewMessageTemplates(c.Templates, statusTemplates)
+	if err != nil {
+		return nil, err
+	}
+
 	// create twitter client
 	config := oauth1.NewConfig(c.ConsumerKey, c.ConsumerSecret)
 	token := oauth1.NewToken(c.AccessToken, c.AccessTokenSecret)
 	httpClient := config.Client(oauth1.NoContext, token)
-	client := twitter.NewClient(httpClient)
-	verifyParams := &twitter.AccountVerifyParams{
-		SkipStatus:   twitter.Bool(true),
-		IncludeEmail: twitter.Bool(true),
-	}
+	httpClient.Timeout = 30 * time.Second
 
-	// verify credentials at least once
-	user, _, err := client.Accounts.VerifyCredentials(verifyParams)
-	if err != nil {
-		return nil, err
+	apiURL := c.APIURL
+	if apiURL == "" {
+		apiURL = DefaultTwitterAPIURL
 	}
-	log.Debugf("connected to twitter as @%s", user.ScreenName)
 
 	notifier := &TwitterNotifier{
-		client:        client,
-		user:          user,
+		client:        httpClient,
+		apiURL:        strings.TrimSuffix(apiURL, "/"),
 		hashtagsMap:   c.Hashtags,
 		db:            db,
 		enableReplies: c.EnableReplies,
 		retentionDays: c.Retention,
+		templates:     templates,
+	}
+
+	// verify credentials at least once
+	var response struct {
+		Data twitterUser `json:"data"`
+	}
+	if err = notifier.request(http.MethodGet, "/2/users/me", nil, &response); err != nil {
+		return nil, err
 	}
+	notifier.user = &response.Data
+	log.Debugf("connected to twitter as @%s", notifier.user.Username)
 
 	// delete old tweets
 	if err = notifier.ensureRetention(); err != nil {
@@ -105,46 +146,106 @@ func (c *TwitterNotifier) ensureRetention() error {
 	return nil
 }
 
-// create a brand new tweet
-func (c *TwitterNotifier) createTweet(message string) (int64, error) {
-	tweet, _, err := c.client.Statuses.Update(message, nil)
+// request calls the Twitter API and decodes the response
+func (c *TwitterNotifier) request(method string, path string, payload interface{}, response interface{}) error {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create new request: %s", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
 	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return fmt.Errorf("twitter returned %d: %s", res.StatusCode, content)
+	}
+	return json.Unmarshal(content, response)
+}
+
+// postTweet creates a tweet, replying to another tweet when tweetID is not zero
+func (c *TwitterNotifier) postTweet(message string, tweetID int64) (int64, error) {
+	payload := twitterTweetRequest{Text: message}
+	if tweetID != 0 {
+		payload.Reply = &twitterTweetReply{InReplyToTweetID: strconv.FormatInt(tweetID, 10)}
+	}
+
+	var response struct {
+		Data twitterTweet `json:"data"`
+	}
+	if err := c.request(http.MethodPost, "/2/tweets", payload, &response); err != nil {
 		return 0, err
 	}
-	log.Debugf("twitter status %d created: %s", tweet.ID, tweet.Text)
-	return tweet.ID, nil
+	id, err := strconv.ParseInt(response.Data.ID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tweet id %s: %s", response.Data.ID, err)
+	}
+	log.Debugf("twitter status %d created: %s", id, response.Data.Text)
+	return id, nil
+}
+
+// create a brand new tweet
+func (c *TwitterNotifier) createTweet(message string) (int64, error) {
+	return c.postTweet(message, 0)
 }
 
 // reply to another tweet
 func (c *TwitterNotifier) replyToTweet(tweetID int64, message string) (int64, error) {
-	message = fmt.Sprintf("@%s %s", c.user.ScreenName, message)
-	tweet, _, err := c.client.Statuses.Update(message, &twitter.StatusUpdateParams{InReplyToStatusID: tweetID})
-	if err != nil {
-		return 0, nil
-	}
-	log.Debugf("twitter status %d created: %s", tweet.ID, tweet.Text)
-	return tweet.ID, nil
+	return c.postTweet(message, tweetID)
 }
 
 // parse product name to build a list of hashtags
 func (c *TwitterNotifier) buildHashtags(productName string) string {
-	productName = strings.ToLower(productName)
-	for _, rule := range c.hashtagsMap {
-		for pattern, value := range rule {
-			if ok, _ := regexp.MatchString(pattern, productName); ok {
-				return value
-			}
-		}
-	}
-	return ""
+	return matchPatterns(c.hashtagsMap, productName)
 }
 
-// NotifyWhenAvailable create a Twitter status for announcing that a product is available
+// String returns the name of the notifier
+func (c *TwitterNotifier) String() string {
+	return "twitter"
+}
+
+// Notify sends a notification when a product becomes available or is sold out again
 // implements the Notifier interface
-func (c *TwitterNotifier) NotifyWhenAvailable(shopName string, productName string, productPrice float64, productCurrency string, productURL string) error {
+func (c *TwitterNotifier) Notify(event *RestockEvent) error {
+	switch event.Type {
+	case EventAvailable:
+		return c.notifyWhenAvailable(event)
+	case EventNotAvailable:
+		return c.notifyWhenNotAvailable(event)
+	case EventPriceDrop:
+		return c.notifyWhenPriceDrop(event)
+	}
+	return nil
+}
+
+// notifyWhenAvailable create a Twitter status for announcing that a product is available
+func (c *TwitterNotifier) notifyWhenAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
+
 	// format message
-	hashtags := c.buildHashtags(productName)
-	message := formatAvailableTweet(shopName, productName, productPrice, productCurrency, productURL, hashtags, 0)
+	data := NewMessageData(event)
+	data.Hashtags = c.buildHashtags(event.Product.Name)
+	message, err := c.templates.AvailableWithMaxSize(data, tweetMaxSize)
+	if err != nil {
+		return err
+	}
 
 	// compute message checksum to avoid duplicates
 	var tweet Tweet
@@ -177,7 +278,11 @@ func (c *TwitterNotifier) NotifyWhenAvailable(shopName string, productName strin
 		// tweet already has been sent in the past
 		// creating new thread with a counter
 		tweet.Counter++
-		message = formatAvailableTweet(shopName, productName, productPrice, productCurrency, productURL, hashtags, tweet.Counter)
+		data.Counter = tweet.Counter
+		message, err = c.templates.AvailableWithMaxSize(data, tweetMaxSize)
+		if err != nil {
+			return err
+		}
 		tweetID, err := c.createTweet(message)
 		if err != nil {
 			return fmt.Errorf("could not create new twitter thread for product '%s': %s", productURL, err)
@@ -195,47 +300,28 @@ func (c *TwitterNotifier) NotifyWhenAvailable(shopName string, productName strin
 	return nil
 }
 
-// formatAvailableTweet creates a message based on product characteristics
-func formatAvailableTweet(shopName string, productName string, productPrice float64, productCurrency string, productURL string, hashtags string, counter int64) string {
-	// format message
-	formattedPrice := formatPrice(productPrice, productCurrency)
-	message := fmt.Sprintf("%s: %s for %s is available at %s %s", shopName, productName, formattedPrice, productURL, hashtags)
-	if counter > 1 {
-		message = fmt.Sprintf("%s (%d)", message, counter)
-	}
-
-	// truncate tweet if too big
-	if utf8.RuneCountInString(message) > tweetMaxSize {
-		messageWithoutProduct := fmt.Sprintf("%s:  for %s is available at %s %s", shopName, formattedPrice, productURL, hashtags)
-		if counter > 1 {
-			messageWithoutProduct = fmt.Sprintf("%s (%d)", messageWithoutProduct, counter)
-		}
-		// maximum tweet size - other characters - additional "…" to say product name has been truncated
-		productNameSize := tweetMaxSize - utf8.RuneCountInString(messageWithoutProduct) - 1
-		format := fmt.Sprintf("%%s: %%.%ds… for %%s is available at %%s %%s", productNameSize)
-		message = fmt.Sprintf(format, shopName, productName, formattedPrice, productURL, hashtags)
-		if counter > 1 {
-			message = fmt.Sprintf("%s (%d)", message, counter)
-		}
-	}
-
-	return message
-}
+// notifyWhenNotAvailable create a Twitter status replying to the notifyWhenAvailable status to say it's over
+func (c *TwitterNotifier) notifyWhenNotAvailable(event *RestockEvent) error {
+	productURL := event.Product.URL
 
-// NotifyWhenNotAvailable create a Twitter status replying to the NotifyWhenAvailable status to say it's over
-// implements the Notifier interface
-func (c *TwitterNotifier) NotifyWhenNotAvailable(productURL string, duration time.Duration) error {
 	// find Tweet in the database
 	var tweet Tweet
 	trx := c.db.Where(Tweet{ProductURL: productURL}).First(&tweet)
-
+	if trx.Error == gorm.ErrRecordNotFound {
+		// product was already available before the notifier was enabled
+		log.Debugf("tweet for product '%s' not found, skipping not available notification", productURL)
+		return nil
+	}
 	if trx.Error != nil {
 		return fmt.Errorf("could not find tweet for product '%s' in the database: %s", productURL, trx.Error)
 	}
 
 	if c.enableReplies {
 		// format message
-		message := fmt.Sprintf("And it's gone (%s)", duration)
+		message, err := c.templates.NotAvailable(NewMessageData(event))
+		if err != nil {
+			return err
+		}
 
 		// select tweet to reply
 		lastTweetID := CoalesceInt64(tweet.LastTweetID, tweet.TweetID)
@@ -262,3 +348,40 @@ func (c *TwitterNotifier) NotifyWhenNotAvailable(productURL string, duration tim
 
 	return nil
 }
+
+// notifyWhenPriceDrop create a Twitter status replying to the last status of the thread with the old and new prices
+func (c *TwitterNotifier) notifyWhenPriceDrop(event *RestockEvent) error {
+	productURL := event.Product.URL
+
+	// find the last Tweet of the product in the database
+	var tweet Tweet
+	trx := c.db.Where(Tweet{ProductURL: productURL}).Order("updated_at desc").First(&tweet)
+	if trx.Error == gorm.ErrRecordNotFound {
+		log.Debugf("tweet for product '%s' not found, skipping price drop notification", productURL)
+		return nil
+	}
+	if trx.Error != nil {
+		return fmt.Errorf("could not find tweet for product '%s' in the database: %s", productURL, trx.Error)
+	}
+
+	message, err := c.templates.PriceDrop(NewMessageData(event))
+	if err != nil {
+		return err
+	}
+
+	// reply to the thread on twitter
+	lastTweetID := CoalesceInt64(tweet.LastTweetID, tweet.TweetID)
+	tweetID, err := c.replyToTweet(lastTweetID, message)
+	if err != nil {
+		return fmt.Errorf("could not reply to tweet %d for product '%s': %s", lastTweetID, productURL, err)
+	}
+	log.Infof("price drop reply to tweet %d sent with id %d for product '%s'", lastTweetID, tweetID, productURL)
+
+	// save tweet id on database
+	tweet.LastTweetID = tweetID
+	if trx = c.db.Save(&tweet); trx.Error != nil {
+		return fmt.Errorf("could not save tweet %d to database for product '%s': %s", tweet.TweetID, productURL, trx.Error)
+	}
+	log.Debugf("tweet %d saved in database", tweet.TweetID)
+	return nil
+}
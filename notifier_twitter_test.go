@@ -1,8 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 	"unicode/utf8"
 )
 
@@ -113,9 +118,23 @@ func TestFormatAvailableTweet(t *testing.T) {
 			"shop.com: my awesome product with very very very very very very very very very very very very very very very very very very very very very very very very very very very very very very very very very very … for $999.99 is available at https://shop.com/awesome #awesome #product (2)",
 		},
 	}
+	templates := mustMessageTemplates(statusTemplates)
 	for i, tc := range tests {
 		t.Run(fmt.Sprintf("TestFormatAvailableTweet#%d", i), func(t *testing.T) {
-			got := formatAvailableTweet(tc.shopName, tc.productName, tc.productPrice, tc.productCurrency, tc.productURL, tc.hashtags, tc.counter)
+			data := &MessageData{
+				Shop:           tc.shopName,
+				Name:           tc.productName,
+				Price:          tc.productPrice,
+				Currency:       tc.productCurrency,
+				FormattedPrice: formatPrice(tc.productPrice, tc.productCurrency),
+				URL:            tc.productURL,
+				Hashtags:       tc.hashtags,
+				Counter:        tc.counter,
+			}
+			got, err := templates.AvailableWithMaxSize(data, tweetMaxSize)
+			if err != nil {
+				t.Fatalf("for %s, got error %s", tc.productName, err)
+			}
 			if got != tc.expected {
 				t.Errorf("for %s, got '%s', want '%s'", tc.productName, got, tc.expected)
 			} else {
@@ -130,3 +149,88 @@ func TestFormatAvailableTweet(t *testing.T) {
 		})
 	}
 }
+
+func TestTwitterNotifier(t *testing.T) {
+	var tweets []twitterTweetRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "OAuth ") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/2/users/me":
+			fmt.Fprint(w, `{"data": {"id": "1", "username": "restockbot"}}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/2/tweets":
+			var tweet twitterTweetRequest
+			if err := json.NewDecoder(r.Body).Decode(&tweet); err != nil {
+				t.Errorf("cannot decode tweet: %s", err)
+			}
+			tweets = append(tweets, tweet)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"data": {"id": "%d", "text": "tweet"}}`, 1000+len(tweets))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	db, product := newNotifierTestDatabase(t)
+	notifier, err := NewTwitterNotifier(&TwitterConfig{
+		ConsumerKey:       "key",
+		ConsumerSecret:    "secret",
+		AccessToken:       "token",
+		AccessTokenSecret: "token-secret",
+		APIURL:            server.URL,
+		EnableReplies:     true,
+	}, db)
+	if err != nil {
+		t.Fatalf("cannot create notifier: %s", err)
+	}
+
+	notifyWhenAvailable := func() error {
+		return notifier.Notify(newAvailableEvent(product))
+	}
+	notifyWhenNotAvailable := func() error {
+		return notifier.Notify(newNotAvailableEvent(product, 90*time.Second))
+	}
+	notifyWhenPriceDrop := func() error {
+		return notifier.Notify(newPriceDropEvent(product, 749.99))
+	}
+
+	tests := []struct {
+		notify    func() error
+		text      string
+		inReplyTo string
+		tweet     Tweet // expected tweet in database
+	}{
+		{notifyWhenAvailable, "ldlc.com: MSI GeForce RTX 3080 GAMING X for 899.99€ is available at https://www.ldlc.com/fiche/PB00385720.html ", "", Tweet{TweetID: 1001, Counter: 1}},
+		{notifyWhenNotAvailable, "And it's gone (1m30s)", "1001", Tweet{TweetID: 1001, LastTweetID: 1002, Counter: 1}},
+		{notifyWhenAvailable, "ldlc.com: MSI GeForce RTX 3080 GAMING X for 899.99€ is available at https://www.ldlc.com/fiche/PB00385720.html  (2)", "", Tweet{TweetID: 1001, LastTweetID: 1003, Counter: 2}},
+		{notifyWhenPriceDrop, "Price drop: 899.99€ → 749.99€", "1003", Tweet{TweetID: 1001, LastTweetID: 1004, Counter: 2}},
+		{notifyWhenNotAvailable, "And it's gone (1m30s)", "1004", Tweet{TweetID: 1001, LastTweetID: 1005, Counter: 2}},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestTwitterNotifier#%d", i), func(t *testing.T) {
+			if err := tc.notify(); err != nil {
+				t.Fatalf("cannot notify: %s", err)
+			}
+			got := tweets[len(tweets)-1]
+			var inReplyTo string
+			if got.Reply != nil {
+				inReplyTo = got.Reply.InReplyToTweetID
+			}
+			if got.Text != tc.text || inReplyTo != tc.inReplyTo {
+				t.Errorf("got tweet '%s' replying to '%s', want tweet '%s' replying to '%s'", got.Text, inReplyTo, tc.text, tc.inReplyTo)
+			}
+
+			var tweet Tweet
+			db.Where(Tweet{ProductURL: product.URL}).First(&tweet)
+			if tweet.TweetID != tc.tweet.TweetID || tweet.LastTweetID != tc.tweet.LastTweetID || tweet.Counter != tc.tweet.Counter {
+				t.Errorf("got tweet %d (last %d, counter %d) in database, want tweet %d (last %d, counter %d)", tweet.TweetID, tweet.LastTweetID, tweet.Counter, tc.tweet.TweetID, tc.tweet.LastTweetID, tc.tweet.Counter)
+			} else {
+				t.Logf("got tweet '%s' replying to '%s'", got.Text, inReplyTo)
+			}
+		})
+	}
+}
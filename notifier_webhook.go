@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Body formats supported by the WebhookNotifier
+const (
+	WebhookFormatJSON = "json"
+	WebhookFormatForm = "form"
+)
+
+// Default values of the WebhookNotifier
+const (
+	DefaultWebhookSignatureHeader = "X-Restockbot-Signature"
+	DefaultWebhookTimeout         = 30 * time.Second
+)
+
+// WebhookEvent to render webhook bodies
+type WebhookEvent struct {
+	Event          string  `json:"event"`
+	ShopName       string  `json:"shop_name"`
+	ProductName    string  `json:"product_name"`
+	ProductURL     string  `json:"product_url"`
+	Price          float64 `json:"price"`
+	Currency       string  `json:"currency"`
+	FormattedPrice string  `json:"formatted_price"`
+	Duration       string  `json:"duration,omitempty"`
+	Date           string  `json:"date"`
+}
+
+// NewWebhookEvent creates a WebhookEvent from a RestockEvent
+func NewWebhookEvent(event *RestockEvent) *WebhookEvent {
+	product := event.Product
+	e := &WebhookEvent{
+		Event:          event.Type,
+		ShopName:       product.Shop.Name,
+		ProductName:    product.Name,
+		ProductURL:     product.URL,
+		Price:          product.Price,
+		Currency:       product.PriceCurrency,
+		FormattedPrice: formatPrice(product.Price, product.PriceCurrency),
+		Date:           event.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if event.Type == EventNotAvailable {
+		e.Duration = event.Duration.String()
+	}
+	return e
+}
+
+// values returns the event as form values
+func (e *WebhookEvent) values() url.Values {
+	values := url.Values{}
+	values.Set("event", e.Event)
+	values.Set("shop_name", e.ShopName)
+	values.Set("product_name", e.ProductName)
+	values.Set("product_url", e.ProductURL)
+	values.Set("price", strconv.FormatFloat(e.Price, 'f', 2, 64))
+	values.Set("currency", e.Currency)
+	values.Set("formatted_price", e.FormattedPrice)
+	if e.Duration != "" {
+		values.Set("duration", e.Duration)
+	}
+	values.Set("date", e.Date)
+	return values
+}
+
+// WebhookNotifier to send events to an HTTP endpoint
+type WebhookNotifier struct {
+	name                 string
+	client               *http.Client
+	url                  string
+	method               string
+	format               string
+	headers              map[string]string
+	secret               string
+	signatureHeader      string
+	availableTemplate    *template.Template
+	notAvailableTemplate *template.Template
+}
+
+// webhookTemplateFuncs are functions available in body templates
+var webhookTemplateFuncs = template.FuncMap{
+	// json encodes a value, including quotes for strings
+	"json": func(value interface{}) (string, error) {
+		b, err := json.Marshal(value)
+		return string(b), err
+	},
+}
+
+// NewWebhookNotifier creates a WebhookNotifier
+func NewWebhookNotifier(config *WebhookConfig) (*WebhookNotifier, error) {
+	if _, err := url.Parse(config.URL); err != nil {
+		return nil, fmt.Errorf("invalid webhook url: %s", err)
+	}
+
+	timeout := time.Duration(config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+
+	notifier := &WebhookNotifier{
+		name:            webhookName(config),
+		client:          &http.Client{Timeout: timeout},
+		url:             config.URL,
+		method:          strings.ToUpper(config.Method),
+		format:          config.Format,
+		headers:         config.Headers,
+		secret:          config.Secret,
+		signatureHeader: config.SignatureHeader,
+	}
+	if notifier.method == "" {
+		notifier.method = http.MethodPost
+	}
+	if notifier.format == "" {
+		notifier.format = WebhookFormatJSON
+	}
+	if notifier.format != WebhookFormatJSON && notifier.format != WebhookFormatForm {
+		return nil, fmt.Errorf("webhook format %s not supported", notifier.format)
+	}
+	if notifier.signatureHeader == "" {
+		notifier.signatureHeader = DefaultWebhookSignatureHeader
+	}
+
+	var err error
+	if config.AvailableTemplate != "" {
+		if notifier.availableTemplate, err = template.New("available").Funcs(webhookTemplateFuncs).Parse(config.AvailableTemplate); err != nil {
+			return nil, fmt.Errorf("cannot parse available template: %s", err)
+		}
+	}
+	if config.NotAvailableTemplate != "" {
+		if notifier.notAvailableTemplate, err = template.New("not_available").Funcs(webhookTemplateFuncs).Parse(config.NotAvailableTemplate); err != nil {
+			return nil, fmt.Errorf("cannot parse not available template: %s", err)
+		}
+	}
+
+	return notifier, nil
+}
+
+// String returns the name of the notifier
+// implements the Notifier interface
+func (n *WebhookNotifier) String() string {
+	return n.name
+}
+
+// webhookName returns a unique name for a webhook configuration
+// Query parameters of the URL, which may contain secrets, are replaced by a hash of the whole configuration
+func webhookName(config *WebhookConfig) string {
+	encoded, _ := json.Marshal(config)
+	sum := sha256.Sum256(encoded)
+	hash := hex.EncodeToString(sum[:])[:8]
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return fmt.Sprintf("webhook %s", hash)
+	}
+	return fmt.Sprintf("webhook %s://%s%s %s", u.Scheme, u.Host, u.Path, hash)
+}
+
+// Notify sends an event to the webhook when a product becomes available or is sold out again
+// implements the Notifier interface
+func (n *WebhookNotifier) Notify(event *RestockEvent) error {
+	switch event.Type {
+	case EventAvailable:
+		return n.send(NewWebhookEvent(event), n.availableTemplate)
+	case EventNotAvailable:
+		return n.send(NewWebhookEvent(event), n.notAvailableTemplate)
+	}
+	return nil
+}
+
+// render the body of an event using the template, or the default encoding of the format
+func (n *WebhookNotifier) render(event *WebhookEvent, tmpl *template.Template) ([]byte, error) {
+	if tmpl != nil {
+		var buffer bytes.Buffer
+		if err := tmpl.Execute(&buffer, event); err != nil {
+			return nil, fmt.Errorf("cannot render webhook template: %s", err)
+		}
+		return buffer.Bytes(), nil
+	}
+	if n.format == WebhookFormatForm {
+		return []byte(event.values().Encode()), nil
+	}
+	return json.Marshal(event)
+}
+
+// sign returns the HMAC-SHA256 signature of a body
+func (n *WebhookNotifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(n.secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// send an event to the webhook
+// Failed requests are retried by the outbox with an exponential backoff
+func (n *WebhookNotifier) send(event *WebhookEvent, tmpl *template.Template) error {
+	body, err := n.render(event, tmpl)
+	if err != nil {
+		return err
+	}
+	if err = n.request(body); err != nil {
+		return fmt.Errorf("failed to send %s event to webhook for product '%s': %s", event.Event, event.ProductURL, err)
+	}
+	log.Infof("%s event sent to webhook for product '%s'", event.Event, event.ProductURL)
+	return nil
+}
+
+// request sends a body to the webhook
+func (n *WebhookNotifier) request(body []byte) error {
+	req, err := http.NewRequest(n.method, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create new request: %s", err)
+	}
+	if n.format == WebhookFormatForm {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range n.headers {
+		req.Header.Set(key, value)
+	}
+	if n.secret != "" {
+		req.Header.Set(n.signatureHeader, n.sign(body))
+	}
+
+	log.Debugf("sending %s to webhook %s", body, n.url)
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		content, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("webhook returned %d: %s", res.StatusCode, content)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var attempts int
+	var body, contentType, signature, token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		content, _ := ioutil.ReadAll(r.Body)
+		body = string(content)
+		contentType = r.Header.Get("Content-Type")
+		signature = r.Header.Get("X-Signature")
+		token = r.Header.Get("X-Token")
+		switch r.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/notfound":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	_, product := newNotifierTestDatabase(t)
+
+	tests := []struct {
+		config      WebhookConfig
+		available   bool   // send available or not available event
+		body        string // expected body, ignored when empty
+		contentType string
+		attempts    int
+		failed      bool
+	}{
+		{ // templated json body with headers and signature
+			WebhookConfig{URL: server.URL, AvailableTemplate: `{"text": {{ printf "%s at %s" .ProductName .FormattedPrice | json }}}`, Headers: map[string]string{"X-Token": "secret"}, Secret: "key", SignatureHeader: "X-Signature"},
+			true, `{"text": "MSI GeForce RTX 3080 GAMING X at 899.99€"}`, "application/json", 1, false,
+		},
+		{ // templated form body filled from database
+			WebhookConfig{URL: server.URL, Format: "form", NotAvailableTemplate: `event={{.Event}}&name={{urlquery .ProductName}}&duration={{.Duration}}`},
+			false, "event=not_available&name=MSI+GeForce+RTX+3080+GAMING+X&duration=1m30s", "application/x-www-form-urlencoded", 1, false,
+		},
+		{ // server errors are not retried, the outbox retries the delivery
+			WebhookConfig{URL: server.URL + "/unavailable"},
+			true, "", "application/json", 1, true,
+		},
+		{ // client errors
+			WebhookConfig{URL: server.URL + "/notfound"},
+			false, "", "application/json", 1, true,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestWebhookNotifier#%d", i), func(t *testing.T) {
+			attempts = 0
+			notifier, err := NewWebhookNotifier(&tc.config)
+			if err != nil {
+				t.Fatalf("cannot create notifier: %s", err)
+			}
+
+			if tc.available {
+				err = notifier.Notify(newAvailableEvent(product))
+			} else {
+				err = notifier.Notify(newNotAvailableEvent(product, 90*time.Second))
+			}
+			if (err != nil) != tc.failed {
+				t.Errorf("got error %v, want failure %t", err, tc.failed)
+			}
+			if attempts != tc.attempts {
+				t.Errorf("got %d attempts, want %d", attempts, tc.attempts)
+			}
+			if tc.body != "" && body != tc.body {
+				t.Errorf("got body %s, want %s", body, tc.body)
+			}
+			if contentType != tc.contentType {
+				t.Errorf("got content type %s, want %s", contentType, tc.contentType)
+			}
+			if tc.config.Secret != "" && (signature != notifier.sign([]byte(body)) || token != "secret") {
+				t.Errorf("got signature %s and token %s, want signature %s and token secret", signature, token, notifier.sign([]byte(body)))
+			}
+			t.Logf("got body %s after %d attempt(s)", body, attempts)
+		})
+	}
+}
+
+func TestWebhookSign(t *testing.T) {
+	notifier := WebhookNotifier{secret: "It's a Secret to Everybody"}
+	// example from https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries
+	expected := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got := notifier.sign([]byte("Hello, World!")); got != expected {
+		t.Errorf("got %s, want %s", got, expected)
+	}
+}
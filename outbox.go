@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Statuses of deliveries
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// Default values of the Dispatcher
+const (
+	DefaultOutboxMaxAttempts = 10
+	DefaultOutboxBackoff     = 30 * time.Second
+	DefaultOutboxInterval    = 30 * time.Second
+	maxOutboxBackoff         = time.Hour
+)
+
+// OutboxEvent stores a RestockEvent to deliver to notifiers
+type OutboxEvent struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	Type       string           `gorm:"not null" json:"type"`
+	ProductID  uint             `gorm:"not null;index" json:"product_id"`
+	Product    Product          `gorm:"constraint:OnDelete:CASCADE" json:"product"`
+	Payload    string           `gorm:"not null" json:"-"`
+	CreatedAt  time.Time        `gorm:"index" json:"created_at"`
+	Deliveries []OutboxDelivery `gorm:"constraint:OnDelete:CASCADE" json:"deliveries,omitempty"`
+}
+
+// NewOutboxEvent creates an OutboxEvent from a RestockEvent
+func NewOutboxEvent(event *RestockEvent) (*OutboxEvent, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode event: %s", err)
+	}
+	return &OutboxEvent{
+		Type:      event.Type,
+		ProductID: event.Product.ID,
+		Payload:   string(payload),
+	}, nil
+}
+
+// RestockEvent decodes the event stored in the outbox
+func (e *OutboxEvent) RestockEvent() (*RestockEvent, error) {
+	var event RestockEvent
+	if err := json.Unmarshal([]byte(e.Payload), &event); err != nil {
+		return nil, fmt.Errorf("cannot decode event %d: %s", e.ID, err)
+	}
+	return &event, nil
+}
+
+// OutboxDelivery stores the delivery status of an OutboxEvent to a notifier
+type OutboxDelivery struct {
+	ID            uint        `gorm:"primaryKey" json:"id"`
+	OutboxEventID uint        `gorm:"not null;index" json:"event_id"`
+	OutboxEvent   OutboxEvent `json:"event"`
+	Notifier      string      `gorm:"not null;index" json:"notifier"`
+	Status        string      `gorm:"not null;index" json:"status"`
+	Attempts      int         `gorm:"not null;default:0" json:"attempts"`
+	LastError     string      `json:"last_error,omitempty"`
+	NextAttemptAt time.Time   `json:"next_attempt_at"`
+	DeliveredAt   *time.Time  `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// Dispatcher to deliver events of the outbox to notifiers
+// Failed deliveries are retried with exponential backoff
+type Dispatcher struct {
+	db          *gorm.DB
+	notifiers   map[string]Notifier
+	names       []string
+	maxAttempts int
+	backoff     time.Duration
+	interval    time.Duration
+	running     int32
+}
+
+// NewDispatcher creates a Dispatcher for a list of notifiers
+// Deliveries are stored by notifier name, which must be unique
+func NewDispatcher(db *gorm.DB, notifiers []Notifier, config OutboxConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		db:          db,
+		notifiers:   make(map[string]Notifier),
+		maxAttempts: config.MaxAttempts,
+		backoff:     time.Duration(config.Backoff) * time.Second,
+		interval:    time.Duration(config.Interval) * time.Second,
+	}
+	if d.maxAttempts == 0 {
+		d.maxAttempts = DefaultOutboxMaxAttempts
+	}
+	if d.backoff == 0 {
+		d.backoff = DefaultOutboxBackoff
+	}
+	if d.interval == 0 {
+		d.interval = DefaultOutboxInterval
+	}
+	for _, notifier := range notifiers {
+		name := notifier.String()
+		if _, ok := d.notifiers[name]; ok {
+			return nil, fmt.Errorf("notifier %s already registered", name)
+		}
+		d.notifiers[name] = notifier
+		d.names = append(d.names, name)
+	}
+	return d, nil
+}
+
+// Enqueue stores an event and one delivery per notifier in the outbox
+// The transaction of the product update should be given to never lose an event
+func (d *Dispatcher) Enqueue(tx *gorm.DB, event *RestockEvent) error {
+	if len(d.names) == 0 {
+		return nil
+	}
+	outboxEvent, err := NewOutboxEvent(event)
+	if err != nil {
+		return err
+	}
+	for _, name := range d.names {
+		outboxEvent.Deliveries = append(outboxEvent.Deliveries, OutboxDelivery{Notifier: name, Status: DeliveryPending, NextAttemptAt: event.CreatedAt})
+	}
+	return tx.Create(outboxEvent).Error
+}
+
+// Dispatch delivers pending events to notifiers and returns the number of sent deliveries
+// Notifiers are called sequentially, a slow notifier delays the others. To never block workers, Dispatch returns
+// immediately when deliveries are already in progress, new events will be delivered by the next call.
+func (d *Dispatcher) Dispatch() int {
+	if !atomic.CompareAndSwapInt32(&d.running, 0, 1) {
+		log.Debugf("deliveries already in progress, skipping dispatch")
+		return 0
+	}
+	defer atomic.StoreInt32(&d.running, 0)
+	return d.dispatch(time.Now())
+}
+
+// dispatch delivers pending events which are due at the given time
+// Events of a product are delivered in order to each notifier, a pending delivery blocks the next ones
+func (d *Dispatcher) dispatch(now time.Time) int {
+	var deliveries []OutboxDelivery
+	trx := d.db.Preload("OutboxEvent").Where(OutboxDelivery{Status: DeliveryPending}).Order("id asc").Find(&deliveries)
+	if trx.Error != nil {
+		log.Warnf("cannot find pending deliveries: %s", trx.Error)
+		return 0
+	}
+
+	sent := 0
+	blocked := make(map[string]bool)
+	for _, delivery := range deliveries {
+		key := fmt.Sprintf("%s/%d", delivery.Notifier, delivery.OutboxEvent.ProductID)
+		if blocked[key] {
+			continue
+		}
+
+		if delivery.NextAttemptAt.After(now) {
+			blocked[key] = true
+			continue
+		}
+
+		// notifier removed from the configuration or notifications disabled
+		notifier, ok := d.notifiers[delivery.Notifier]
+		if !ok {
+			delivery.Status = DeliveryFailed
+			delivery.LastError = "notifier not configured"
+			log.Warnf("notifier %s not configured, delivery %d failed", delivery.Notifier, delivery.ID)
+			if trx = d.db.Omit("OutboxEvent").Save(&delivery); trx.Error != nil {
+				log.Warnf("cannot save delivery %d to database: %s", delivery.ID, trx.Error)
+			}
+			continue
+		}
+
+		event, err := delivery.OutboxEvent.RestockEvent()
+		if err == nil {
+			err = notifier.Notify(event)
+		}
+
+		delivery.Attempts++
+		if err == nil {
+			delivery.Status = DeliverySent
+			delivery.LastError = ""
+			delivery.DeliveredAt = &now
+			sent++
+			log.Debugf("delivery %d of %s event sent to %s", delivery.ID, delivery.OutboxEvent.Type, delivery.Notifier)
+		} else if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = DeliveryFailed
+			delivery.LastError = err.Error()
+			log.Errorf("delivery %d to %s failed after %d attempts: %s", delivery.ID, delivery.Notifier, delivery.Attempts, err)
+		} else {
+			delivery.LastError = err.Error()
+			delivery.NextAttemptAt = now.Add(d.computeBackoff(delivery.Attempts))
+			blocked[key] = true
+			log.Warnf("delivery %d to %s failed (attempt %d/%d), retrying at %s: %s", delivery.ID, delivery.Notifier, delivery.Attempts, d.maxAttempts, delivery.NextAttemptAt.Format(time.RFC3339), err)
+		}
+
+		if trx = d.db.Omit("OutboxEvent").Save(&delivery); trx.Error != nil {
+			log.Warnf("cannot save delivery %d to database: %s", delivery.ID, trx.Error)
+		}
+	}
+	return sent
+}
+
+// computeBackoff returns the time to wait after a number of failed attempts
+func (d *Dispatcher) computeBackoff(attempts int) time.Duration {
+	backoff := d.backoff
+	for i := 1; i < attempts && backoff < maxOutboxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxOutboxBackoff {
+		backoff = maxOutboxBackoff
+	}
+	return backoff
+}
+
+// Run dispatches events periodically until the context is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.Dispatch()
+		}
+	}
+}
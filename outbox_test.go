@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakeNotifier records types of notified events after a number of failures
+type fakeNotifier struct {
+	name     string
+	failures int
+	events   []string
+}
+
+func (n *fakeNotifier) String() string {
+	return n.name
+}
+
+func (n *fakeNotifier) Notify(event *RestockEvent) error {
+	if n.failures > 0 {
+		n.failures--
+		return errors.New("service unavailable")
+	}
+	n.events = append(n.events, event.Type)
+	return nil
+}
+
+// newOutboxTestDatabase creates a database with an available product and outbox tables
+func newOutboxTestDatabase(t *testing.T) (*gorm.DB, Product) {
+	db, product := newNotifierTestDatabase(t)
+	if err := db.AutoMigrate(&OutboxEvent{}, &OutboxDelivery{}); err != nil {
+		t.Fatalf("cannot migrate database: %s", err)
+	}
+	return db, product
+}
+
+func TestComputeBackoff(t *testing.T) {
+	d, _ := NewDispatcher(nil, nil, OutboxConfig{Backoff: 30})
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{20, time.Hour}, // capped
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestComputeBackoff#%d", i), func(t *testing.T) {
+			got := d.computeBackoff(tc.attempts)
+			if got != tc.expected {
+				t.Errorf("for %d attempts, got %s, want %s", tc.attempts, got, tc.expected)
+			} else {
+				t.Logf("for %d attempts, got %s", tc.attempts, got)
+			}
+		})
+	}
+}
+
+func TestNewDispatcher(t *testing.T) {
+	webhook := func(u string) Notifier {
+		n, err := NewWebhookNotifier(&WebhookConfig{URL: u})
+		if err != nil {
+			t.Fatalf("cannot create webhook notifier: %s", err)
+		}
+		return n
+	}
+
+	tests := []struct {
+		notifiers []Notifier
+		err       bool
+	}{
+		{[]Notifier{&fakeNotifier{name: "first"}, &fakeNotifier{name: "second"}}, false},
+		{[]Notifier{webhook("https://host/hook?token=A"), webhook("https://host/hook?token=B")}, false}, // query parameters differ
+		{[]Notifier{&fakeNotifier{name: "first"}, &fakeNotifier{name: "first"}}, true},
+		{[]Notifier{webhook("https://host/hook?token=A"), webhook("https://host/hook?token=A")}, true},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestNewDispatcher#%d", i), func(t *testing.T) {
+			_, err := NewDispatcher(nil, tc.notifiers, OutboxConfig{})
+			if tc.err && err == nil {
+				t.Errorf("for %v, got no error, want an error", tc.notifiers)
+			} else if !tc.err && err != nil {
+				t.Errorf("for %v, got error %s", tc.notifiers, err)
+			} else {
+				t.Logf("for %v, got error %v", tc.notifiers, err)
+			}
+		})
+	}
+}
+
+func TestDispatcherEnqueue(t *testing.T) {
+	db, product := newOutboxTestDatabase(t)
+	d, _ := NewDispatcher(db, []Notifier{&fakeNotifier{name: "first"}, &fakeNotifier{name: "second"}}, OutboxConfig{})
+
+	// event is not stored when the transaction is rolled back
+	db.Transaction(func(tx *gorm.DB) error {
+		if err := d.Enqueue(tx, newAvailableEvent(product)); err != nil {
+			t.Fatalf("cannot enqueue event: %s", err)
+		}
+		return errors.New("rollback")
+	})
+	var count int64
+	db.Model(&OutboxEvent{}).Count(&count)
+	if count != 0 {
+		t.Errorf("got %d events after rollback, want 0", count)
+	}
+
+	// one pending delivery per notifier
+	db.Transaction(func(tx *gorm.DB) error {
+		return d.Enqueue(tx, newAvailableEvent(product))
+	})
+	var deliveries []OutboxDelivery
+	db.Preload("OutboxEvent").Where(OutboxDelivery{Status: DeliveryPending}).Find(&deliveries)
+	if len(deliveries) != 2 {
+		t.Fatalf("got %d pending deliveries, want 2", len(deliveries))
+	}
+	event, err := deliveries[0].OutboxEvent.RestockEvent()
+	if err != nil {
+		t.Fatalf("got error %s", err)
+	}
+	if event.Type != EventAvailable || event.Product.URL != product.URL || event.Product.Shop.Name != "ldlc.com" {
+		t.Errorf("got event %+v, want %s event of %s", event, EventAvailable, product.URL)
+	}
+}
+
+func TestDispatcherDispatch(t *testing.T) {
+	db, product := newOutboxTestDatabase(t)
+	healthy := &fakeNotifier{name: "healthy"}
+	flaky := &fakeNotifier{name: "flaky", failures: 2}
+	d, _ := NewDispatcher(db, []Notifier{healthy, flaky}, OutboxConfig{MaxAttempts: 3, Backoff: 10})
+
+	d.Enqueue(db, newAvailableEvent(product))
+	d.Enqueue(db, newNotAvailableEvent(product, time.Minute))
+	now := time.Now()
+
+	tests := []struct {
+		at            time.Time
+		sent          int
+		healthyEvents []string
+		flakyEvents   []string
+	}{
+		{now, 2, []string{EventAvailable, EventNotAvailable}, nil},                                                               // first attempt of flaky fails and blocks the next event
+		{now.Add(5 * time.Second), 0, []string{EventAvailable, EventNotAvailable}, nil},                                          // retry is not due yet
+		{now.Add(10 * time.Second), 0, []string{EventAvailable, EventNotAvailable}, nil},                                         // second attempt fails
+		{now.Add(30 * time.Second), 2, []string{EventAvailable, EventNotAvailable}, []string{EventAvailable, EventNotAvailable}}, // events delivered in order
+		{now.Add(time.Hour), 0, []string{EventAvailable, EventNotAvailable}, []string{EventAvailable, EventNotAvailable}},        // nothing left
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestDispatcherDispatch#%d", i), func(t *testing.T) {
+			sent := d.dispatch(tc.at)
+			if sent != tc.sent || !reflect.DeepEqual(healthy.events, tc.healthyEvents) || !reflect.DeepEqual(flaky.events, tc.flakyEvents) {
+				t.Errorf("got %d sent and events %v and %v, want %d sent and events %v and %v", sent, healthy.events, flaky.events, tc.sent, tc.healthyEvents, tc.flakyEvents)
+			} else {
+				t.Logf("got %d sent and events %v and %v", sent, healthy.events, flaky.events)
+			}
+		})
+	}
+
+	var deliveries []OutboxDelivery
+	db.Where(OutboxDelivery{Notifier: "flaky"}).Order("id asc").Find(&deliveries)
+	if len(deliveries) != 2 || deliveries[0].Status != DeliverySent || deliveries[0].Attempts != 3 || deliveries[0].DeliveredAt == nil || deliveries[1].Attempts != 1 {
+		t.Errorf("got deliveries %+v, want the first one sent after 3 attempts and the second one after 1 attempt", deliveries)
+	}
+}
+
+func TestDispatcherMaxAttempts(t *testing.T) {
+	db, product := newOutboxTestDatabase(t)
+	broken := &fakeNotifier{name: "broken", failures: 2}
+	d, _ := NewDispatcher(db, []Notifier{broken}, OutboxConfig{MaxAttempts: 2, Backoff: 10})
+
+	d.Enqueue(db, newAvailableEvent(product))
+	d.Enqueue(db, newNotAvailableEvent(product, time.Minute))
+	now := time.Now()
+
+	d.dispatch(now)
+	sent := d.dispatch(now.Add(10 * time.Second))
+
+	var delivery OutboxDelivery
+	db.Order("id asc").First(&delivery)
+	if delivery.Status != DeliveryFailed || delivery.Attempts != 2 || delivery.LastError != "service unavailable" {
+		t.Errorf("got delivery %+v, want failed after 2 attempts", delivery)
+	}
+
+	// a failed delivery doesn't block the next events
+	if sent != 1 || !reflect.DeepEqual(broken.events, []string{EventNotAvailable}) {
+		t.Errorf("got %d sent and events %v, want 1 sent and events [%s]", sent, broken.events, EventNotAvailable)
+	}
+}
+
+func TestDispatcherNotifierNotConfigured(t *testing.T) {
+	db, product := newOutboxTestDatabase(t)
+	removed := &fakeNotifier{name: "removed"}
+	d, _ := NewDispatcher(db, []Notifier{removed}, OutboxConfig{})
+	d.Enqueue(db, newAvailableEvent(product))
+
+	// notifier removed from the configuration
+	d, _ = NewDispatcher(db, []Notifier{&fakeNotifier{name: "other"}}, OutboxConfig{})
+	d.dispatch(time.Now())
+
+	var delivery OutboxDelivery
+	db.First(&delivery)
+	if delivery.Status != DeliveryFailed || delivery.LastError != "notifier not configured" || len(removed.events) != 0 {
+		t.Errorf("got delivery %+v, want failed because the notifier is not configured", delivery)
+	}
+}
+
+func TestDispatcherNotAvailableWithoutMessage(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"id": "%d"}`, 1000+requests)
+	}))
+	defer server.Close()
+
+	db, product := newOutboxTestDatabase(t)
+	discord, err := NewDiscordNotifier(&DiscordConfig{WebhookURL: server.URL + "/api/webhooks/1/token", EnableReplies: true}, db)
+	if err != nil {
+		t.Fatalf("cannot create notifier: %s", err)
+	}
+	d, _ := NewDispatcher(db, []Notifier{discord}, OutboxConfig{})
+
+	// product was already available before the notifier was enabled
+	d.Enqueue(db, newNotAvailableEvent(product, time.Minute))
+	d.Enqueue(db, newAvailableEvent(product))
+
+	if sent := d.dispatch(time.Now()); sent != 2 {
+		t.Errorf("got %d sent, want 2", sent)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want only the available message", requests)
+	}
+
+	var deliveries []OutboxDelivery
+	db.Order("id asc").Find(&deliveries)
+	for _, delivery := range deliveries {
+		if delivery.Status != DeliverySent || delivery.Attempts != 1 {
+			t.Errorf("got delivery %+v, want sent after 1 attempt", delivery)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
+import "context"
+
 // Parser interface to parse an external service and return a list of products
 type Parser interface {
-	Parse() ([]*Product, error)
+	Parse(context.Context) ([]*Product, error)
 	String() string
 	ShopName() (string, error)
 }
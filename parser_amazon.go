@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -51,7 +52,7 @@ func NewAmazonParser(marketplace string, partnerTag string, accessKey string, se
 
 // Parse Amazon API to return list of products
 // Implements Parser interface
-func (p *AmazonParser) Parse() ([]*Product, error) {
+func (p *AmazonParser) Parse(ctx context.Context) ([]*Product, error) {
 
 	var products []*Product
 
@@ -65,7 +66,7 @@ func (p *AmazonParser) Parse() ([]*Product, error) {
 			p.client.PartnerTag(),
 			p.client.PartnerType(),
 		).Search(query.Keywords, search).EnableItemInfo().EnableOffers()
-		body, err := p.client.Request(q)
+		body, err := p.client.RequestContext(ctx, q)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -96,13 +97,13 @@ type NvidiaFEResponse struct {
 
 // Parse NVIDIA store API to return list of products
 // Implements Parser interface
-func (p *NvidiaFEParser) Parse() ([]*Product, error) {
+func (p *NvidiaFEParser) Parse(ctx context.Context) ([]*Product, error) {
 	var products []*Product
 	for _, gpu := range p.gpus {
 		sku := nvidiaSKUs[gpu]
 		apiURL := fmt.Sprintf("https://api.store.nvidia.com/partner/v1/feinventory?status=1&skus=%s&locale=%s-%s", sku, p.location, p.location)
 
-		req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create new request: %s", err)
 		}
@@ -110,7 +111,7 @@ func (p *NvidiaFEParser) Parse() ([]*Product, error) {
 		req.Header.Set("User-Agent", p.userAgent)
 		req.Header.Set("Accept", "application/json")
 
-		log.Debugf("requesting NVIDIA API: %s", req)
+		log.Debugf("requesting NVIDIA API: %s", req.URL)
 
 		res, err := p.client.Do(req)
 		if err != nil {
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ProductPageParser to watch the page of a single product
+// Values are read from schema.org metadata then overridden by CSS selectors from configuration
+// Implements the Parser interface
+type ProductPageParser struct {
+	config   ProductPageConfig
+	selector *SelectorParser
+}
+
+// NewProductPageParser creates a ProductPageParser from configuration
+func NewProductPageParser(config ProductPageConfig, browserAddress string) (*ProductPageParser, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if config.Driver == "" {
+		config.Driver = SelectorDriverHTML
+	}
+	if config.Driver != SelectorDriverHTML && config.Price == "" && config.Availability == "" {
+		return nil, fmt.Errorf("price or availability selector is required with driver %s", config.Driver)
+	}
+	// schema.org metadata and page title are only read with the html driver
+	if config.Driver != SelectorDriverHTML && config.Name == "" {
+		return nil, fmt.Errorf("name selector is required with driver %s", config.Driver)
+	}
+	if config.Driver != SelectorDriverHTML && config.Currency == "" {
+		return nil, fmt.Errorf("currency is required with driver %s", config.Driver)
+	}
+
+	// the whole page is the only item
+	selector, err := newSelectorParser(SelectorConfig{
+		URL:              config.URL,
+		Driver:           config.Driver,
+		Item:             "html",
+		Name:             config.Name,
+		Price:            config.Price,
+		PriceRegex:       config.PriceRegex,
+		Currency:         config.Currency,
+		Availability:     config.Availability,
+		AvailableRegex:   config.AvailableRegex,
+		UnavailableRegex: config.UnavailableRegex,
+		MaxPages:         1,
+		UserAgent:        config.UserAgent,
+		Timeout:          config.Timeout,
+	}, browserAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProductPageParser{config: config, selector: selector}, nil
+}
+
+// String to print ProductPageParser
+// Implements the Parser interface
+func (p *ProductPageParser) String() string {
+	return fmt.Sprintf("ProductPageParser<%s>", p.config.URL)
+}
+
+// ShopName returns shop name from URL
+// Implements the Parser interface
+func (p *ProductPageParser) ShopName() (string, error) {
+	return ExtractShopName(p.config.URL)
+}
+
+// Parse the page to return exactly one product
+// Implements the Parser interface
+func (p *ProductPageParser) Parse(ctx context.Context) ([]*Product, error) {
+	var product *Product
+	var item *selectorItem
+
+	if p.config.Driver == SelectorDriverHTML {
+		doc, err := fetchDocument(ctx, p.selector.client, p.config.URL, p.config.UserAgent)
+		if err != nil {
+			return nil, err
+		}
+		if products := extractSchemaOrgProducts(doc); len(products) > 0 {
+			product = products[0]
+		} else {
+			product = &Product{Name: strings.TrimSpace(doc.Find("title").First().Text())}
+		}
+		root := p.selector.newItem(doc.Find("html").First())
+		item = &root
+	} else {
+		product = &Product{}
+		result, err := p.selector.parseFerretPage(ctx, p.config.URL)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Items) > 0 {
+			item = &result.Items[0]
+		}
+	}
+
+	if item != nil {
+		p.merge(product, *item)
+	}
+
+	product.URL = p.config.URL
+	if product.Name == "" {
+		return nil, fmt.Errorf("product name not found on %s", p.config.URL)
+	}
+
+	return []*Product{product}, nil
+}
+
+// merge overrides values of a product by values extracted with selectors
+func (p *ProductPageParser) merge(product *Product, item selectorItem) {
+	base, _ := url.Parse(p.config.URL)
+	values := p.selector.newProduct(item, base)
+
+	if p.config.Name != "" && values.Name != "" {
+		product.Name = values.Name
+	}
+	if p.config.Price != "" && item.Price != "" {
+		product.Price = values.Price
+	}
+	if p.config.Currency != "" {
+		product.PriceCurrency = p.config.Currency
+	}
+	if p.config.Availability != "" {
+		product.Available = values.Available
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const productPageJSONLD = `<html><head>
+<title>MSI RTX 3080 - Shop</title>
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@type": "Product", "name": "MSI GeForce RTX 3080 GAMING X",
+ "offers": {"@type": "Offer", "price": "899.99", "priceCurrency": "EUR", "availability": "https://schema.org/OutOfStock"}}
+</script>
+</head><body><h1>MSI GeForce RTX 3080</h1></body></html>`
+
+const productPageButton = `<html><head><title>ASUS RTX 3070 DUAL | Shop</title></head><body>
+<h1>ASUS GeForce RTX 3070 DUAL</h1>
+<span class="price">549€95</span>
+<button class="add-to-cart">Add to cart</button>
+</body></html>`
+
+const productPageEmpty = `<html><body><p>Nothing here</p></body></html>`
+
+func TestProductPageParser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/jsonld.html":
+			fmt.Fprint(w, productPageJSONLD)
+		case "/button.html":
+			fmt.Fprint(w, productPageButton)
+		default:
+			fmt.Fprint(w, productPageEmpty)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		config   ProductPageConfig
+		expected *Product // nil when an error is expected
+	}{
+		{ // schema.org metadata only
+			ProductPageConfig{URL: server.URL + "/jsonld.html"},
+			&Product{Name: "MSI GeForce RTX 3080 GAMING X", URL: server.URL + "/jsonld.html", Price: 899.99, PriceCurrency: "EUR", Available: false},
+		},
+		{ // availability selector overrides schema.org metadata
+			ProductPageConfig{URL: server.URL + "/jsonld.html", Availability: "h1"},
+			&Product{Name: "MSI GeForce RTX 3080 GAMING X", URL: server.URL + "/jsonld.html", Price: 899.99, PriceCurrency: "EUR", Available: true},
+		},
+		{ // buy button found
+			ProductPageConfig{URL: server.URL + "/button.html", Name: "h1", Price: ".price", Currency: "EUR", Availability: ".add-to-cart"},
+			&Product{Name: "ASUS GeForce RTX 3070 DUAL", URL: server.URL + "/button.html", Price: 549.95, PriceCurrency: "EUR", Available: true},
+		},
+		{ // buy button not found, name from title
+			ProductPageConfig{URL: server.URL + "/button.html", Price: ".price", Currency: "EUR", Availability: ".buy-now"},
+			&Product{Name: "ASUS RTX 3070 DUAL | Shop", URL: server.URL + "/button.html", Price: 549.95, PriceCurrency: "EUR", Available: false},
+		},
+		{ // product not found
+			ProductPageConfig{URL: server.URL + "/empty.html", Name: "h1"},
+			nil,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestProductPageParser#%d", i), func(t *testing.T) {
+			parser, err := NewProductPageParser(tc.config, "")
+			if err != nil {
+				t.Fatalf("cannot create parser: %s", err)
+			}
+			products, err := parser.Parse(context.Background())
+			if tc.expected == nil {
+				if err == nil {
+					t.Errorf("got %+v, want an error", products)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %s", err)
+			}
+			if len(products) != 1 {
+				t.Fatalf("got %d products, want 1", len(products))
+			}
+			got := products[0]
+			if got.Name != tc.expected.Name || got.URL != tc.expected.URL || got.Price != tc.expected.Price || got.PriceCurrency != tc.expected.PriceCurrency || got.Available != tc.expected.Available {
+				t.Errorf("got %+v, want %+v", got, tc.expected)
+			} else {
+				t.Logf("got %+v", got)
+			}
+		})
+	}
+}
+
+func TestNewProductPageParserErrors(t *testing.T) {
+	tests := []ProductPageConfig{
+		{},                                       // missing url
+		{URL: "https://shop.com", Driver: "cdp"}, // selectors required by Ferret drivers
+		{URL: "https://shop.com", Driver: "http", Price: ".price", Currency: "EUR"},              // name required by Ferret drivers
+		{URL: "https://shop.com", Driver: "http", Name: "h1", Price: ".price"},                   // currency required by Ferret drivers
+		{URL: "https://shop.com", Driver: "other", Name: "h1", Price: ".price", Currency: "EUR"}, // unsupported driver
+		{URL: "https://shop.com", AvailableRegex: "("},
+	}
+	for i, config := range tests {
+		t.Run(fmt.Sprintf("TestNewProductPageParserErrors#%d", i), func(t *testing.T) {
+			if _, err := NewProductPageParser(config, ""); err == nil {
+				t.Errorf("got no error, want an error")
+			}
+		})
+	}
+}
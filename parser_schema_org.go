@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMaxPages to stop following next pages
+const DefaultMaxPages = 10
+
+// Availability values considered as in stock (see https://schema.org/ItemAvailability)
+var schemaOrgAvailabilities = []string{"InStock", "InStoreOnly", "LimitedAvailability", "OnlineOnly", "PreSale"}
+
+// SchemaOrgParser to parse products described by schema.org JSON-LD or microdata
+// Pages are fetched over plain HTTP without headless browser
+// Implements the Parser interface
+type SchemaOrgParser struct {
+	url       string
+	maxPages  int
+	userAgent string
+	client    *http.Client
+}
+
+// NewSchemaOrgParser creates a SchemaOrgParser for a web page
+// Next pages are followed up to maxPages
+func NewSchemaOrgParser(url string, maxPages int, userAgent string, timeout int) *SchemaOrgParser {
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	return &SchemaOrgParser{
+		url:       url,
+		maxPages:  maxPages,
+		userAgent: userAgent,
+		client:    &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}
+}
+
+// String to print SchemaOrgParser
+// Implements the Parser interface
+func (p *SchemaOrgParser) String() string {
+	return fmt.Sprintf("SchemaOrgParser<%s>", p.url)
+}
+
+// ShopName returns shop name from URL
+// Implements the Parser interface
+func (p *SchemaOrgParser) ShopName() (string, error) {
+	return ExtractShopName(p.url)
+}
+
+// Parse pages to return list of products
+// Implements the Parser interface
+func (p *SchemaOrgParser) Parse(ctx context.Context) ([]*Product, error) {
+	var products []*Product
+	visited := make(map[string]bool)
+	seen := make(map[string]bool)
+
+	page := p.url
+	for i := 0; page != "" && !visited[page] && i < p.maxPages; i++ {
+		visited[page] = true
+
+		doc, err := fetchDocument(ctx, p.client, page, p.userAgent)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, product := range extractSchemaOrgProducts(doc) {
+			if !seen[product.URL] {
+				seen[product.URL] = true
+				products = append(products, product)
+			}
+		}
+
+		page = findNextPage(doc)
+	}
+
+	return products, nil
+}
+
+// fetchDocument requests a web page and parses its HTML
+func fetchDocument(ctx context.Context, client *http.Client, page string, userAgent string) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, page, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new request: %s", err)
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	req.Header.Set("Accept", "text/html")
+
+	log.Debugf("requesting %s", page)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %s", page, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", page, res.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML of %s: %s", page, err)
+	}
+	doc.Url = res.Request.URL
+	return doc, nil
+}
+
+// findNextPage returns the absolute URL of the next page from pagination hints, or an empty string
+func findNextPage(doc *goquery.Document) string {
+	href, ok := doc.Find(`link[rel="next"], a[rel="next"]`).First().Attr("href")
+	if !ok || href == "" {
+		return ""
+	}
+	return resolveURL(doc.Url, href)
+}
+
+// resolveURL returns an absolute URL from a link relative to a page
+func resolveURL(base *url.URL, link string) string {
+	link = strings.TrimSpace(link)
+	if base == nil || link == "" {
+		return link
+	}
+	u, err := base.Parse(link)
+	if err != nil {
+		return link
+	}
+	return u.String()
+}
+
+// extractSchemaOrgProducts returns products from JSON-LD scripts then from microdata
+func extractSchemaOrgProducts(doc *goquery.Document) []*Product {
+	var nodes []map[string]interface{}
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			log.Debugf("cannot decode JSON-LD: %s", err)
+			return
+		}
+		nodes = append(nodes, findJSONLDProducts(data)...)
+	})
+
+	var items []*goquery.Selection
+	doc.Find(`[itemscope][itemtype]`).Each(func(_ int, s *goquery.Selection) {
+		if hasSchemaOrgType(s.AttrOr("itemtype", ""), "Product") && !isNestedInProduct(s) {
+			items = append(items, s)
+		}
+	})
+
+	// the page URL identifies a product only on single product pages, which can describe it with both JSON-LD and microdata
+	single := len(nodes) <= 1 && len(items) <= 1
+
+	var products []*Product
+	add := func(product *Product) {
+		if product.URL == "" {
+			if !single || doc.Url == nil {
+				log.Debugf("skipping product '%s' without url on %s", product.Name, doc.Url)
+				return
+			}
+			product.URL = doc.Url.String()
+		}
+		products = append(products, product)
+	}
+	for _, node := range nodes {
+		add(newProductFromJSONLD(node, doc.Url))
+	}
+	for _, s := range items {
+		add(newProductFromMicrodata(s, doc.Url))
+	}
+	return products
+}
+
+// isNestedInProduct returns true when a microdata item is a property of a Product or an Offer (ex: isRelatedTo)
+// Products nested in lists (ex: ItemList, OfferCatalog) are kept
+func isNestedInProduct(s *goquery.Selection) bool {
+	nested := false
+	s.ParentsFiltered(`[itemscope]`).EachWithBreak(func(_ int, parent *goquery.Selection) bool {
+		itemType := parent.AttrOr("itemtype", "")
+		nested = hasSchemaOrgType(itemType, "Product") || hasSchemaOrgType(itemType, "Offer") || hasSchemaOrgType(itemType, "AggregateOffer")
+		return !nested
+	})
+	return nested
+}
+
+// findJSONLDProducts walks through a JSON-LD document to find Product nodes
+func findJSONLDProducts(data interface{}) []map[string]interface{} {
+	var nodes []map[string]interface{}
+	switch value := data.(type) {
+	case []interface{}:
+		for _, element := range value {
+			nodes = append(nodes, findJSONLDProducts(element)...)
+		}
+	case map[string]interface{}:
+		if hasJSONLDType(value, "Product") {
+			return append(nodes, value)
+		}
+		// @graph, itemListElement, item, etc
+		for _, element := range value {
+			nodes = append(nodes, findJSONLDProducts(element)...)
+		}
+	}
+	return nodes
+}
+
+// hasJSONLDType returns true when the @type of a node is or contains the expected type
+func hasJSONLDType(node map[string]interface{}, expected string) bool {
+	switch value := node["@type"].(type) {
+	case string:
+		return hasSchemaOrgType(value, expected)
+	case []interface{}:
+		for _, element := range value {
+			if s, ok := element.(string); ok && hasSchemaOrgType(s, expected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasSchemaOrgType compares types with or without the schema.org prefix
+func hasSchemaOrgType(value string, expected string) bool {
+	for _, t := range strings.Fields(value) {
+		t = strings.TrimSuffix(t, "/")
+		if t == expected || strings.HasSuffix(t, "schema.org/"+expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSchemaOrgAvailable returns true when an availability value means the product is in stock
+// ex: "https://schema.org/InStock", "http://schema.org/InStock", "InStock"
+func isSchemaOrgAvailable(availability string) bool {
+	availability = strings.TrimSpace(availability)
+	for _, value := range schemaOrgAvailabilities {
+		if availability == value || strings.HasSuffix(availability, "schema.org/"+value) {
+			return true
+		}
+	}
+	return false
+}
+
+// newProductFromJSONLD creates a Product from a JSON-LD Product node
+func newProductFromJSONLD(node map[string]interface{}, base *url.URL) *Product {
+	product := &Product{
+		Name: strings.TrimSpace(jsonLDString(node["name"])),
+		URL:  jsonLDString(node["url"]),
+	}
+	if product.URL == "" {
+		product.URL = jsonLDString(node["@id"])
+	}
+	product.URL = resolveURL(base, product.URL)
+
+	// select the first offer in stock or the first offer
+	offers := findJSONLDOffers(node["offers"])
+	for i, offer := range offers {
+		available := isSchemaOrgAvailable(jsonLDString(offer["availability"]))
+		if i == 0 || available {
+			product.Price = jsonLDPrice(offer)
+			product.PriceCurrency = jsonLDString(offer["priceCurrency"])
+			product.Available = available
+		}
+		if available {
+			break
+		}
+	}
+	return product
+}
+
+// findJSONLDOffers returns a flat list of offers, including offers of aggregated offers
+func findJSONLDOffers(data interface{}) []map[string]interface{} {
+	var offers []map[string]interface{}
+	switch value := data.(type) {
+	case []interface{}:
+		for _, element := range value {
+			offers = append(offers, findJSONLDOffers(element)...)
+		}
+	case map[string]interface{}:
+		if nested, ok := value["offers"]; ok {
+			offers = append(offers, findJSONLDOffers(nested)...)
+		}
+		if len(offers) == 0 {
+			offers = append(offers, value)
+		}
+	}
+	return offers
+}
+
+// jsonLDPrice returns the price of an offer, the lowest price of an aggregated offer or its price specification
+func jsonLDPrice(offer map[string]interface{}) float64 {
+	for _, key := range []string{"price", "lowPrice"} {
+		if price, err := parseSchemaOrgPrice(jsonLDString(offer[key])); err == nil {
+			return price
+		}
+	}
+	if specification, ok := offer["priceSpecification"].(map[string]interface{}); ok {
+		return jsonLDPrice(specification)
+	}
+	return 0
+}
+
+// parseSchemaOrgPrice parses a machine-readable price, with a dot as decimal separator (ex: "1299.000")
+// Free-text prices are parsed as a fallback
+func parseSchemaOrgPrice(value string) (float64, error) {
+	if price, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+		return price, nil
+	}
+	return ParsePrice(value)
+}
+
+// jsonLDString converts a JSON-LD value to string
+// References like {"@id": "https://..."} are converted to their identifier
+func jsonLDString(data interface{}) string {
+	switch value := data.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case map[string]interface{}:
+		return jsonLDString(value["@id"])
+	case []interface{}:
+		if len(value) > 0 {
+			return jsonLDString(value[0])
+		}
+	}
+	return ""
+}
+
+// newProductFromMicrodata creates a Product from an element with the schema.org/Product item type
+func newProductFromMicrodata(s *goquery.Selection, base *url.URL) *Product {
+	product := &Product{
+		Name: strings.TrimSpace(microdataValue(s, "name")),
+		URL:  microdataValue(s, "url"),
+	}
+	product.URL = resolveURL(base, product.URL)
+
+	offer := microdataProperty(s, "offers")
+	if offer.Length() == 0 {
+		offer = s
+	}
+	if price, err := microdataPrice(offer); err == nil {
+		product.Price = price
+	}
+	product.PriceCurrency = strings.TrimSpace(microdataValue(offer, "priceCurrency"))
+	product.Available = isSchemaOrgAvailable(microdataValue(offer, "availability"))
+	return product
+}
+
+// microdataProperty returns the first element of a property of an item, ignoring properties of nested items (ex: brand, seller)
+func microdataProperty(s *goquery.Selection, property string) *goquery.Selection {
+	return s.Find(fmt.Sprintf(`[itemprop="%s"]`, property)).FilterFunction(func(_ int, element *goquery.Selection) bool {
+		return element.Parent().Closest(`[itemscope]`).IsSelection(s)
+	}).First()
+}
+
+// microdataPrice returns the price of an offer from the machine-readable content attribute or from the text
+func microdataPrice(offer *goquery.Selection) (float64, error) {
+	element := microdataProperty(offer, "price")
+	if content, ok := element.Attr("content"); ok {
+		return parseSchemaOrgPrice(content)
+	}
+	return ParsePrice(microdataValue(offer, "price"))
+}
+
+// microdataValue returns the value of the first property found in an item
+func microdataValue(s *goquery.Selection, property string) string {
+	element := microdataProperty(s, property)
+	if element.Length() == 0 {
+		return ""
+	}
+	for _, attribute := range []string{"content", "href", "src"} {
+		if value, ok := element.Attr(attribute); ok {
+			return value
+		}
+	}
+	return element.Text()
+}
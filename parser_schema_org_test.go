@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const schemaOrgFirstPage = `<html>
+<head>
+<link rel="next" href="/gpu?page=2">
+<script type="application/ld+json">
+{
+  "@context": "https://schema.org",
+  "@graph": [
+    {"@type": "BreadcrumbList", "itemListElement": []},
+    {
+      "@type": "ItemList",
+      "itemListElement": [
+        {"@type": "ListItem", "position": 1, "item": {
+          "@type": "Product",
+          "name": "MSI GeForce RTX 3080 GAMING X",
+          "url": "/products/msi-rtx-3080",
+          "offers": {"@type": "Offer", "price": "899.990", "priceCurrency": "EUR", "availability": "https://schema.org/InStock"}
+        }},
+        {"@type": "ListItem", "position": 2, "item": {
+          "@type": "Product",
+          "name": "ASUS GeForce RTX 3070 DUAL",
+          "url": "https://shop.com/products/asus-rtx-3070",
+          "offers": {"@type": "AggregateOffer", "lowPrice": 649, "priceCurrency": "EUR", "offers": [
+            {"@type": "Offer", "price": 699, "priceCurrency": "EUR", "availability": "http://schema.org/OutOfStock"},
+            {"@type": "Offer", "price": 649, "priceCurrency": "EUR", "availability": "OutOfStock"}
+          ]}
+        }}
+      ]
+    }
+  ]
+}
+</script>
+<script type="application/ld+json">not json</script>
+</head>
+<body></body>
+</html>`
+
+const schemaOrgSecondPage = `<html>
+<body>
+<div itemscope itemtype="https://schema.org/ItemList">
+  <div itemprop="itemListElement" itemscope itemtype="https://schema.org/Product">
+    <a itemprop="url" href="/products/palit-rtx-3060"><span itemprop="name">Palit GeForce RTX 3060 Dual</span></a>
+    <div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+      <div itemprop="seller" itemscope itemtype="https://schema.org/Organization"><span itemprop="name">Marketplace</span></div>
+      <span itemprop="price" content="399.90">399,90 €</span>
+      <meta itemprop="priceCurrency" content="EUR">
+      <link itemprop="availability" href="https://schema.org/LimitedAvailability">
+    </div>
+  </div>
+  <div itemprop="itemListElement" itemscope itemtype="https://schema.org/Product">
+    <div itemprop="brand" itemscope itemtype="https://schema.org/Brand"><span itemprop="name">MSI</span></div>
+    <a itemprop="url" href="/products/msi-rtx-3060"><span itemprop="name">MSI GeForce RTX 3060 VENTUS</span></a>
+    <div itemprop="isRelatedTo" itemscope itemtype="https://schema.org/Product"><span itemprop="name">MSI cable</span></div>
+    <div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+      <span itemprop="price">1.429,90 €</span>
+      <meta itemprop="priceCurrency" content="EUR">
+      <link itemprop="availability" href="https://schema.org/OutOfStock">
+    </div>
+  </div>
+</div>
+<a rel="next" href="/gpu?page=1">previous page already visited</a>
+</body>
+</html>`
+
+func TestSchemaOrgParser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, schemaOrgSecondPage)
+		} else {
+			fmt.Fprint(w, schemaOrgFirstPage)
+		}
+	}))
+	defer server.Close()
+
+	parser := NewSchemaOrgParser(server.URL+"/gpu?page=1", 0, "restockbot", 5)
+	products, err := parser.Parse(context.Background())
+	if err != nil {
+		t.Fatalf("cannot parse: %s", err)
+	}
+
+	expected := []Product{
+		{Name: "MSI GeForce RTX 3080 GAMING X", URL: server.URL + "/products/msi-rtx-3080", Price: 899.99, PriceCurrency: "EUR", Available: true},
+		{Name: "ASUS GeForce RTX 3070 DUAL", URL: "https://shop.com/products/asus-rtx-3070", Price: 699, PriceCurrency: "EUR", Available: false},
+		{Name: "Palit GeForce RTX 3060 Dual", URL: server.URL + "/products/palit-rtx-3060", Price: 399.90, PriceCurrency: "EUR", Available: true},
+		{Name: "MSI GeForce RTX 3060 VENTUS", URL: server.URL + "/products/msi-rtx-3060", Price: 1429.90, PriceCurrency: "EUR", Available: false}, // brand and related product ignored, price parsed from text
+	}
+
+	if len(products) != len(expected) {
+		t.Fatalf("got %d products, want %d", len(products), len(expected))
+	}
+
+	for i, tc := range expected {
+		t.Run(fmt.Sprintf("TestSchemaOrgParser#%d", i), func(t *testing.T) {
+			got := products[i]
+			if got.Name != tc.Name || got.URL != tc.URL || got.Price != tc.Price || got.PriceCurrency != tc.PriceCurrency || got.Available != tc.Available {
+				t.Errorf("got %+v, want %+v", got, tc)
+			} else {
+				t.Logf("got %+v", got)
+			}
+		})
+	}
+}
+
+func TestExtractSchemaOrgProducts(t *testing.T) {
+	page, _ := url.Parse("https://shop.com/products/msi-rtx-3080")
+
+	tests := []struct {
+		html     string
+		expected []string // URLs of products
+	}{
+		{ // single product page without url
+			`<script type="application/ld+json">{"@type": "Product", "name": "MSI GeForce RTX 3080 GAMING X"}</script>`,
+			[]string{"https://shop.com/products/msi-rtx-3080"},
+		},
+		{ // single product described with JSON-LD and microdata
+			`<script type="application/ld+json">{"@type": "Product", "name": "MSI GeForce RTX 3080 GAMING X"}</script>
+			<div itemscope itemtype="https://schema.org/Product"><span itemprop="name">MSI GeForce RTX 3080 GAMING X</span></div>`,
+			[]string{"https://shop.com/products/msi-rtx-3080", "https://shop.com/products/msi-rtx-3080"},
+		},
+		{ // listing page, products without url are skipped
+			`<script type="application/ld+json">[
+				{"@type": "Product", "name": "MSI GeForce RTX 3080 GAMING X"},
+				{"@type": "Product", "name": "ASUS GeForce RTX 3070 DUAL"},
+				{"@type": "Product", "name": "Palit GeForce RTX 3060 Dual", "@id": "/products/palit-rtx-3060"}
+			]</script>`,
+			[]string{"https://shop.com/products/palit-rtx-3060"},
+		},
+		{ // listing page with microdata
+			`<div itemscope itemtype="https://schema.org/Product"><span itemprop="name">MSI GeForce RTX 3080 GAMING X</span></div>
+			<div itemscope itemtype="https://schema.org/Product"><a itemprop="url" href="/products/asus-rtx-3070">ASUS GeForce RTX 3070 DUAL</a></div>`,
+			[]string{"https://shop.com/products/asus-rtx-3070"},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestExtractSchemaOrgProducts#%d", i), func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tc.html))
+			if err != nil {
+				t.Fatalf("cannot parse html: %s", err)
+			}
+			doc.Url = page
+
+			var got []string
+			for _, product := range extractSchemaOrgProducts(doc) {
+				got = append(got, product.URL)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("got %v, want %v", got, tc.expected)
+			} else {
+				t.Logf("got %v", got)
+			}
+		})
+	}
+}
+
+func TestIsSchemaOrgAvailable(t *testing.T) {
+	tests := []struct {
+		value     string
+		available bool
+	}{
+		{"https://schema.org/InStock", true},
+		{"http://schema.org/InStock", true},
+		{"InStock", true},
+		{"https://schema.org/OutOfStock", false},
+		{"https://schema.org/SoldOut", false},
+		{"", false},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestIsSchemaOrgAvailable#%d", i), func(t *testing.T) {
+			got := isSchemaOrgAvailable(tc.value)
+			if got != tc.available {
+				t.Errorf("for '%s', got %t, want %t", tc.value, got, tc.available)
+			} else {
+				t.Logf("for '%s', got %t, want %t", tc.value, got, tc.available)
+			}
+		})
+	}
+}
+
+func TestParseSchemaOrgPrice(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+	}{
+		{"1299.000", 1299},
+		{"899.99", 899.99},
+		{" 649 ", 649},
+		{"1.299,90 €", 1299.90}, // free text
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestParseSchemaOrgPrice#%d", i), func(t *testing.T) {
+			got, err := parseSchemaOrgPrice(tc.value)
+			if err != nil || got != tc.expected {
+				t.Errorf("for '%s', got %f (error %v), want %f", tc.value, got, err, tc.expected)
+			} else {
+				t.Logf("for '%s', got %f", tc.value, got)
+			}
+		})
+	}
+}
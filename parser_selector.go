@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/MontFerret/ferret/pkg/drivers"
+	"github.com/MontFerret/ferret/pkg/drivers/cdp"
+	ferrethttp "github.com/MontFerret/ferret/pkg/drivers/http"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Drivers supported by the SelectorParser
+const (
+	// SelectorDriverHTML fetches pages over plain HTTP and parses HTML without Ferret
+	SelectorDriverHTML = "html"
+	// SelectorDriverHTTP uses the Ferret HTTP driver
+	SelectorDriverHTTP = "http"
+	// SelectorDriverCDP uses the Ferret headless browser driver
+	SelectorDriverCDP = "cdp"
+)
+
+// Ferret query returning raw values of a single page
+// Selectors are passed as parameters to avoid injections
+const selectorQuery = `
+LET doc = DOCUMENT(@url, {driver: @driver})
+
+LET items = (
+    FOR el IN ELEMENTS(doc, @item)
+        LET name = @name != "" ? ELEMENT(el, @name) : NONE
+        LET link = @link != "" ? ELEMENT(el, @link) : NONE
+        LET price = @price != "" ? ELEMENT(el, @price) : NONE
+        LET availability = @availability != "" ? ELEMENT(el, @availability) : NONE
+        RETURN {
+            name: name != NONE ? INNER_TEXT(name) : "",
+            link: link != NONE ? link.attributes.href : "",
+            price: price != NONE ? (price.attributes.content != NONE ? price.attributes.content : INNER_TEXT(price)) : "",
+            availability_found: availability != NONE,
+            availability: availability != NONE ? INNER_TEXT(availability) : "",
+        }
+)
+
+LET next = @pagination != "" ? ELEMENT(doc, @pagination) : NONE
+
+RETURN {
+    items: items,
+    next: next != NONE ? next.attributes.href : "",
+}
+`
+
+// selectorPage stores raw values extracted from a page before conversion to products
+type selectorPage struct {
+	Items []selectorItem `json:"items"`
+	Next  string         `json:"next"`
+}
+
+// selectorItem stores raw values of a product
+type selectorItem struct {
+	Name              string `json:"name"`
+	Link              string `json:"link"`
+	Price             string `json:"price"`
+	AvailabilityFound bool   `json:"availability_found"`
+	Availability      string `json:"availability"`
+}
+
+// SelectorParser to parse shops using CSS selectors from configuration
+// Implements the Parser interface
+type SelectorParser struct {
+	config           SelectorConfig
+	priceRegex       *regexp.Regexp
+	availableRegex   *regexp.Regexp
+	unavailableRegex *regexp.Regexp
+	client           *http.Client
+	cdpDriver        drivers.Driver
+	httpDriver       drivers.Driver
+}
+
+// NewSelectorParser creates a SelectorParser from configuration
+func NewSelectorParser(config SelectorConfig, browserAddress string) (*SelectorParser, error) {
+	if config.URL == "" || config.Item == "" || config.Name == "" || config.Link == "" || config.Price == "" {
+		return nil, fmt.Errorf("url, item, name, link and price selectors are required")
+	}
+	return newSelectorParser(config, browserAddress)
+}
+
+// newSelectorParser creates a SelectorParser without checking required selectors
+func newSelectorParser(config SelectorConfig, browserAddress string) (*SelectorParser, error) {
+	var err error
+
+	if config.Driver == "" {
+		config.Driver = SelectorDriverHTML
+	}
+	if !ContainsString([]string{SelectorDriverHTML, SelectorDriverHTTP, SelectorDriverCDP}, config.Driver) {
+		return nil, fmt.Errorf("driver %s not supported", config.Driver)
+	}
+
+	if config.MaxPages <= 0 {
+		config.MaxPages = DefaultMaxPages
+	}
+
+	parser := &SelectorParser{config: config}
+
+	if config.PriceRegex != "" {
+		if parser.priceRegex, err = regexp.Compile(config.PriceRegex); err != nil {
+			return nil, fmt.Errorf("cannot compile price regex: %s", err)
+		}
+	}
+	if config.AvailableRegex != "" {
+		if parser.availableRegex, err = regexp.Compile(config.AvailableRegex); err != nil {
+			return nil, fmt.Errorf("cannot compile available regex: %s", err)
+		}
+	}
+	if config.UnavailableRegex != "" {
+		if parser.unavailableRegex, err = regexp.Compile(config.UnavailableRegex); err != nil {
+			return nil, fmt.Errorf("cannot compile unavailable regex: %s", err)
+		}
+	}
+
+	if config.Driver == SelectorDriverHTML {
+		parser.client = &http.Client{Timeout: time.Duration(config.Timeout) * time.Second}
+	} else {
+		parser.cdpDriver = cdp.NewDriver(cdp.WithAddress(browserAddress))
+		parser.httpDriver = ferrethttp.NewDriver()
+	}
+
+	return parser, nil
+}
+
+// String to print SelectorParser
+// Implements the Parser interface
+func (p *SelectorParser) String() string {
+	return fmt.Sprintf("SelectorParser<%s>", p.config.URL)
+}
+
+// ShopName returns shop name from URL
+// Implements the Parser interface
+func (p *SelectorParser) ShopName() (string, error) {
+	return ExtractShopName(p.config.URL)
+}
+
+// Parse pages to return list of products
+// Implements the Parser interface
+func (p *SelectorParser) Parse(ctx context.Context) ([]*Product, error) {
+	var products []*Product
+	visited := make(map[string]bool)
+
+	page := p.config.URL
+	for i := 0; page != "" && !visited[page] && i < p.config.MaxPages; i++ {
+		visited[page] = true
+
+		var result *selectorPage
+		var err error
+		if p.config.Driver == SelectorDriverHTML {
+			result, err = p.parseHTMLPage(ctx, page)
+		} else {
+			result, err = p.parseFerretPage(ctx, page)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		base, err := url.Parse(page)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range result.Items {
+			products = append(products, p.newProduct(item, base))
+		}
+
+		page = ""
+		if result.Next != "" {
+			page = resolveURL(base, result.Next)
+		}
+	}
+
+	return products, nil
+}
+
+// parseHTMLPage extracts raw values from a page fetched over plain HTTP
+func (p *SelectorParser) parseHTMLPage(ctx context.Context, page string) (*selectorPage, error) {
+	doc, err := fetchDocument(ctx, p.client, page, p.config.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &selectorPage{}
+	doc.Find(p.config.Item).Each(func(_ int, s *goquery.Selection) {
+		result.Items = append(result.Items, p.newItem(s))
+	})
+
+	if p.config.Pagination != "" {
+		result.Next = doc.Find(p.config.Pagination).First().AttrOr("href", "")
+	}
+	return result, nil
+}
+
+// newItem extracts raw values from an item element
+func (p *SelectorParser) newItem(s *goquery.Selection) selectorItem {
+	var item selectorItem
+	if p.config.Name != "" {
+		item.Name = s.Find(p.config.Name).First().Text()
+	}
+	if p.config.Link != "" {
+		item.Link = s.Find(p.config.Link).First().AttrOr("href", "")
+	}
+	if p.config.Price != "" {
+		price := s.Find(p.config.Price).First()
+		item.Price = price.AttrOr("content", price.Text())
+	}
+	if p.config.Availability != "" {
+		availability := s.Find(p.config.Availability).First()
+		item.AvailabilityFound = availability.Length() > 0
+		item.Availability = availability.Text()
+	}
+	return item
+}
+
+// parseFerretPage extracts raw values from a page using Ferret drivers
+func (p *SelectorParser) parseFerretPage(ctx context.Context, page string) (*selectorPage, error) {
+	params := map[string]interface{}{
+		"url":          page,
+		"driver":       p.config.Driver,
+		"item":         p.config.Item,
+		"name":         p.config.Name,
+		"link":         p.config.Link,
+		"price":        p.config.Price,
+		"availability": p.config.Availability,
+		"pagination":   p.config.Pagination,
+	}
+	out, err := runQuery(ctx, selectorQuery, params, p.cdpDriver, p.httpDriver)
+	if err != nil {
+		return nil, err
+	}
+	var result selectorPage
+	if err = json.Unmarshal(out, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// newProduct converts raw values to a Product using rules from configuration
+func (p *SelectorParser) newProduct(item selectorItem, base *url.URL) *Product {
+	product := &Product{
+		Name:          strings.Join(strings.Fields(item.Name), " "),
+		URL:           resolveURL(base, item.Link),
+		PriceCurrency: p.config.Currency,
+	}
+
+	price := item.Price
+	if p.priceRegex != nil {
+		if matches := p.priceRegex.FindStringSubmatch(price); len(matches) > 1 {
+			price = matches[1]
+		} else if len(matches) == 1 {
+			price = matches[0]
+		}
+	}
+	if value, err := ParsePrice(price); err == nil {
+		product.Price = value
+	}
+
+	availability := strings.TrimSpace(item.Availability)
+	switch {
+	case p.config.Availability == "":
+		product.Available = true
+	case p.availableRegex != nil:
+		product.Available = p.availableRegex.MatchString(availability)
+	case p.unavailableRegex != nil:
+		product.Available = !p.unavailableRegex.MatchString(availability)
+	default:
+		product.Available = item.AvailabilityFound
+	}
+
+	return product
+}
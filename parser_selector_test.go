@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MontFerret/ferret/pkg/compiler"
+)
+
+const selectorFirstPage = `<html><body>
+<div class="pdt-item">
+  <h3>MSI GeForce RTX 3080
+      GAMING X</h3>
+  <a href="/fiche/msi-3080.html">details</a>
+  <div class="price">899€99</div>
+  <div class="stock-web"><span>En stock</span></div>
+</div>
+<div class="pdt-item">
+  <h3>ASUS GeForce RTX 3070 DUAL</h3>
+  <a href="/fiche/asus-3070.html">details</a>
+  <div class="price">1 049€95</div>
+  <div class="stock-web"><span>RUPTURE</span></div>
+</div>
+<ul class="pagination"><li class="next"><a href="/gpu/page2.html">next</a></li></ul>
+</body></html>`
+
+const selectorSecondPage = `<html><body>
+<div class="pdt-item">
+  <h3>Palit GeForce RTX 3060 Dual</h3>
+  <a href="https://cdn.shop.com/fiche/palit-3060.html">details</a>
+  <div class="price" content="399.90">399€90</div>
+  <div class="stock-web"><span>Sous 7 jours</span></div>
+</div>
+</body></html>`
+
+func TestSelectorParser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gpu/page2.html" {
+			fmt.Fprint(w, selectorSecondPage)
+		} else {
+			fmt.Fprint(w, selectorFirstPage)
+		}
+	}))
+	defer server.Close()
+
+	parser, err := NewSelectorParser(SelectorConfig{
+		URL:              server.URL + "/gpu/page1.html",
+		Item:             ".pdt-item",
+		Name:             "h3",
+		Link:             "a",
+		Price:            ".price",
+		PriceRegex:       `[0-9 ]+€[0-9]*`,
+		Currency:         "EUR",
+		Availability:     ".stock-web span",
+		UnavailableRegex: "RUPTURE",
+		Pagination:       ".pagination .next a",
+	}, "")
+	if err != nil {
+		t.Fatalf("cannot create parser: %s", err)
+	}
+
+	products, err := parser.Parse(context.Background())
+	if err != nil {
+		t.Fatalf("cannot parse: %s", err)
+	}
+
+	expected := []Product{
+		{Name: "MSI GeForce RTX 3080 GAMING X", URL: server.URL + "/fiche/msi-3080.html", Price: 899.99, PriceCurrency: "EUR", Available: true},
+		{Name: "ASUS GeForce RTX 3070 DUAL", URL: server.URL + "/fiche/asus-3070.html", Price: 1049.95, PriceCurrency: "EUR", Available: false},
+		{Name: "Palit GeForce RTX 3060 Dual", URL: "https://cdn.shop.com/fiche/palit-3060.html", Price: 399.90, PriceCurrency: "EUR", Available: true},
+	}
+
+	if len(products) != len(expected) {
+		t.Fatalf("got %d products, want %d", len(products), len(expected))
+	}
+
+	for i, tc := range expected {
+		t.Run(fmt.Sprintf("TestSelectorParser#%d", i), func(t *testing.T) {
+			got := products[i]
+			if got.Name != tc.Name || got.URL != tc.URL || got.Price != tc.Price || got.PriceCurrency != tc.PriceCurrency || got.Available != tc.Available {
+				t.Errorf("got %+v, want %+v", got, tc)
+			} else {
+				t.Logf("got %+v", got)
+			}
+		})
+	}
+}
+
+func TestSelectorQuery(t *testing.T) {
+	program, err := compiler.New().Compile(selectorQuery)
+	if err != nil {
+		t.Fatalf("cannot compile selector query: %s", err)
+	}
+	for _, param := range []string{"url", "driver", "item", "name", "link", "price", "availability", "pagination"} {
+		if !ContainsString(program.Params(), param) {
+			t.Errorf("parameter %s not found in selector query", param)
+		}
+	}
+}
+
+func TestNewSelectorParserErrors(t *testing.T) {
+	valid := SelectorConfig{URL: "https://shop.com", Item: ".item", Name: "h3", Link: "a", Price: ".price"}
+
+	tests := []func(c SelectorConfig) SelectorConfig{
+		func(c SelectorConfig) SelectorConfig { c.Item = ""; return c },          // missing selector
+		func(c SelectorConfig) SelectorConfig { c.Driver = "unknown"; return c }, // unsupported driver
+		func(c SelectorConfig) SelectorConfig { c.PriceRegex = "("; return c },   // invalid regex
+	}
+
+	for i, modify := range tests {
+		t.Run(fmt.Sprintf("TestNewSelectorParserErrors#%d", i), func(t *testing.T) {
+			if _, err := NewSelectorParser(modify(valid), ""); err == nil {
+				t.Errorf("got no error, want an error")
+			}
+		})
+	}
+}
@@ -11,12 +11,15 @@ import (
 	"github.com/MontFerret/ferret/pkg/drivers"
 	"github.com/MontFerret/ferret/pkg/drivers/cdp"
 	"github.com/MontFerret/ferret/pkg/drivers/http"
+	"github.com/MontFerret/ferret/pkg/runtime"
 )
 
 // URLParser structure to handle websites parsing logic
 type URLParser struct {
-	url string
-	ctx context.Context
+	url        string
+	registry   *ShopRegistry
+	cdpDriver  drivers.Driver
+	httpDriver drivers.Driver
 }
 
 // String to print URLParser
@@ -31,39 +34,33 @@ func (p *URLParser) ShopName() (string, error) {
 }
 
 // NewURLParser to create a new URLParser instance
-func NewURLParser(url string, browserAddress string) *URLParser {
-
-	log.Debugf("creating context with headless browser drivers")
-	ctx := context.Background()
-	ctx = drivers.WithContext(ctx, cdp.NewDriver(cdp.WithAddress(browserAddress)))
-	ctx = drivers.WithContext(ctx, http.NewDriver(), drivers.AsDefault())
+// Queries are provided by the ShopRegistry
+func NewURLParser(url string, browserAddress string, registry *ShopRegistry) *URLParser {
 
+	log.Debugf("creating headless browser drivers")
 	return &URLParser{
-		url: url,
-		ctx: ctx,
+		url:        url,
+		registry:   registry,
+		cdpDriver:  cdp.NewDriver(cdp.WithAddress(browserAddress)),
+		httpDriver: http.NewDriver(),
 	}
 }
 
 // Parse a website to return list of products
 // Implements Parser interface
 // TODO: redirect output to logger
-func (p *URLParser) Parse() ([]*Product, error) {
+func (p *URLParser) Parse(ctx context.Context) ([]*Product, error) {
 	shopName, err := ExtractShopName(p.url)
 	if err != nil {
 		return nil, err
 	}
 
-	query, err := createQuery(shopName, p.url)
-	if err != nil {
-		return nil, err
-	}
-	comp := compiler.New()
-	program, err := comp.Compile(string(query))
+	query, params, err := p.registry.Query(shopName, p.url)
 	if err != nil {
 		return nil, err
 	}
 
-	out, err := program.Run(p.ctx)
+	out, err := runQuery(ctx, query, params, p.cdpDriver, p.httpDriver)
 	if err != nil {
 		return nil, err
 	}
@@ -76,37 +73,25 @@ func (p *URLParser) Parse() ([]*Product, error) {
 	return products, nil
 }
 
-func createQuery(shopName string, url string) (string, error) {
-	switch shopName {
-	case "cybertek.fr":
-		return createQueryForCybertek(url), nil
-	case "cdiscount.com":
-		return createQueryForCdiscount(url), nil
-	case "ldlc.com":
-		return createQueryForLDLC(url), nil
-	case "materiel.net":
-		return createQueryForMaterielNet(url), nil
-	case "microcenter.com":
-		return createQueryForMicroCenter(url), nil
-	case "mediamarkt.ch":
-		return createQueryForMediamarktCh(url), nil
-	case "newegg.com":
-		return createQueryForNewegg(url), nil
-	case "steg-electronics.ch":
-		return createQueryForStegElectronics(url), nil
-	case "topachat.com":
-		return createQueryForTopachat(url), nil
-	case "vsgamers.es":
-		return createQueryForVersusGamers(url), nil
-	default:
-		return "", fmt.Errorf("shop %s not supported", shopName)
+// runQuery runs a Ferret query with parameters using headless browser and HTTP drivers
+// Parameters are never concatenated to the query to avoid injections
+func runQuery(ctx context.Context, query string, params map[string]interface{}, cdpDriver drivers.Driver, httpDriver drivers.Driver) ([]byte, error) {
+	comp := compiler.New()
+	program, err := comp.Compile(query)
+	if err != nil {
+		return nil, err
 	}
+
+	// attach drivers to the context of this execution
+	ctx = drivers.WithContext(ctx, cdpDriver)
+	ctx = drivers.WithContext(ctx, httpDriver, drivers.AsDefault())
+
+	return program.Run(ctx, runtime.WithParams(params))
 }
 
-func createQueryForLDLC(url string) string {
-	q := `
+const queryForLDLC = `
 // gather first page
-LET first_page  = '` + url + `'
+LET first_page  = @url
 LET doc = DOCUMENT(first_page, {driver: "cdp"})
 
 // discover next pages
@@ -143,13 +128,10 @@ LET results = (
 // combine all arrays to a single one
 RETURN FLATTEN(results)
 	`
-	return q
-}
 
-func createQueryForMaterielNet(url string) string {
-	q := `
+const queryForMaterielNet = `
 // gather first page
-LET first_page  = '` + url + `'
+LET first_page  = @url
 LET doc = DOCUMENT(first_page, {driver: "cdp"})
 
 // discover next pages
@@ -187,12 +169,9 @@ LET results = (
 // combine all arrays to a single one
 RETURN FLATTEN(results)
 	`
-	return q
-}
 
-func createQueryForTopachat(url string) string {
-	q := `
-LET page = '` + url + `'
+const queryForTopachat = `
+LET page = @url
 LET doc = DOCUMENT(page, {driver: "cdp"})
 
 FOR el IN ELEMENTS(doc, "article .grille-produit")
@@ -208,13 +187,10 @@ FOR el IN ELEMENTS(doc, "article .grille-produit")
         available: available,
     }
 	`
-	return q
-}
 
-func createQueryForCybertek(url string) string {
-	q := `
+const queryForCybertek = `
 // gather first page
-LET first_page  = '` + url + `'
+LET first_page  = @url
 LET doc = DOCUMENT(first_page, {driver: "cdp"})
 LET home_page = 'https://www.cybertek.fr/boutique/index.aspx'
 
@@ -267,12 +243,9 @@ LET results = (
 // combine all arrays to a single one
 RETURN FLATTEN(results)
     `
-	return q
-}
 
-func createQueryForMediamarktCh(url string) string {
-	q := `
-LET page = '` + url + `'
+const queryForMediamarktCh = `
+LET page = @url
 LET doc = DOCUMENT(page, {driver: "cdp"})
 
 LET pagination = (
@@ -304,12 +277,9 @@ LET results = (
 
 RETURN FLATTEN(results)
 	`
-	return q
-}
 
-func createQueryForMicroCenter(url string) string {
-	q := `
-LET first_page = '` + url + `'
+const queryForMicroCenter = `
+LET first_page = @url
 LET doc = DOCUMENT(first_page, {driver: "cdp"})
 LET base_url = 'https://www.microcenter.com'
 
@@ -348,12 +318,9 @@ LET results = (
 
 RETURN FLATTEN(results)
 	`
-	return q
-}
 
-func createQueryForNewegg(url string) string {
-	q := `
-LET first_page = '` + url + `'
+const queryForNewegg = `
+LET first_page = @url
 LET doc = DOCUMENT(first_page, {driver: "cdp"})
 
 LET pagination = LAST(ELEMENTS(doc, 'div .list-tool-pagination'))
@@ -388,12 +355,9 @@ LET results = (
 
 RETURN FLATTEN(results)
 	`
-	return q
-}
 
-func createQueryForStegElectronics(url string) string {
-	q := `
-LET first_page = '` + url + `'
+const queryForStegElectronics = `
+LET first_page = @url
 LET doc = DOCUMENT(first_page, { driver: "cdp" })
 
 LET next_pages = (
@@ -425,17 +389,14 @@ LET results = (
 
 RETURN FLATTEN(results)
 	`
-	return q
-}
 
 /*
 * TODO:
 *  - pagination (relies on scroll down move to feed the "div.vs-product-list" element with new items)
 *  - remove products with "name: null"
  */
-func createQueryForVersusGamers(url string) string {
-	q := `
-LET first_page = '` + url + `'
+const queryForVersusGamers = `
+LET first_page = @url
 LET doc = DOCUMENT(first_page, { driver: "cdp" })
 LET base_url = 'https://www.vsgamers.es'
 
@@ -459,8 +420,6 @@ FOR el IN ELEMENTS(doc, "div.vs-product-list div.vs-product-list-item")
         price_currency: price_currency,
     }
 	`
-	return q
-}
 
 /*
  * TODO:
@@ -468,9 +427,8 @@ FOR el IN ELEMENTS(doc, "div.vs-product-list div.vs-product-list-item")
  *   - list unavailable products
  *   - add pagination
  */
-func createQueryForCdiscount(url string) string {
-	q := `
-LET page = '` + url + `'
+const queryForCdiscount = `
+LET page = @url
 LET doc = DOCUMENT(page, {driver: "cdp"})
 
 WAIT_ELEMENT(doc, '.lpMain')
@@ -494,5 +452,3 @@ FOR product IN ELEMENTS(main, '.lpTdgProduct')
     }
 )
 `
-	return q
-}
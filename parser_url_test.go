@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/MontFerret/ferret/pkg/compiler"
+)
+
+func TestBuiltinQueries(t *testing.T) {
+	registry := NewShopRegistry()
+
+	for shopName, query := range registry.queries {
+		t.Run(fmt.Sprintf("TestBuiltinQueries#%s", shopName), func(t *testing.T) {
+			program, err := compiler.New().Compile(query)
+			if err != nil {
+				t.Fatalf("for %s, cannot compile query: %s", shopName, err)
+			}
+			params := program.Params()
+			if len(params) != 1 || params[0] != "url" {
+				t.Errorf("for %s, got params %v, want [url]", shopName, params)
+			} else {
+				t.Logf("for %s, got params %v", shopName, params)
+			}
+		})
+	}
+}
+
+func TestURLParserHostileURL(t *testing.T) {
+	tests := []string{
+		"https://shop.com/products",
+		"https://shop.com/products?name=it's",
+		"https://shop.com/products'\nRETURN \"injected\"\n//",
+		"https://shop.com/products\"\nRETURN 1 //",
+		"https://shop.com/products\\'",
+		"https://shop.com/products`@url`",
+	}
+
+	registry := NewShopRegistry()
+	for i, url := range tests {
+		t.Run(fmt.Sprintf("TestURLParserHostileURL#%d", i), func(t *testing.T) {
+			parser := NewURLParser(url, "", registry)
+			query, params, err := registry.Query("ldlc.com", url)
+			if err != nil {
+				t.Fatalf("cannot find query: %s", err)
+			}
+			if strings.Contains(query, url) {
+				t.Errorf("for %s, url has been injected into the query", url)
+			}
+
+			// run a query returning the parameter as is
+			out, err := runQuery(context.Background(), `RETURN @url`, params, parser.cdpDriver, parser.httpDriver)
+			if err != nil {
+				t.Fatalf("for %s, got error %s", url, err)
+			}
+			var got string
+			if err = json.Unmarshal(out, &got); err != nil {
+				t.Fatalf("for %s, cannot decode %s: %s", url, out, err)
+			}
+			if got != url {
+				t.Errorf("got %s, want %s", got, url)
+			} else {
+				t.Logf("got %s, want %s", strings.ReplaceAll(got, "\n", "\\n"), strings.ReplaceAll(url, "\n", "\\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// JobHandler to process a parser and return the number of parsed products
+type JobHandler func(context.Context, Parser) (int, error)
+
+// ParseResult to store the outcome of a parser execution
+type ParseResult struct {
+	Parser   string
+	ShopName string
+	Products int
+	Duration time.Duration
+	Err      error
+}
+
+// String returns a string to print a ParseResult nicely
+func (r ParseResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s failed after %s: %s", r.Parser, r.Duration, r.Err)
+	}
+	return fmt.Sprintf("%s parsed %d product(s) in %s", r.Parser, r.Products, r.Duration)
+}
+
+// job to submit a parser to the pool and receive its result
+type job struct {
+	parser Parser
+	result chan ParseResult
+}
+
+// WorkerPool to process parsers with a fixed number of long-lived workers pulling from a queue
+type WorkerPool struct {
+	workers int
+	timeout time.Duration
+	handler JobHandler
+	jobs    chan job
+	wg      sync.WaitGroup
+}
+
+// NewWorkerPool creates a WorkerPool
+// A job is cancelled when it reaches the timeout (disabled when zero)
+func NewWorkerPool(workers int, timeout time.Duration, handler JobHandler) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &WorkerPool{
+		workers: workers,
+		timeout: timeout,
+		handler: handler,
+		jobs:    make(chan job),
+	}
+}
+
+// Start workers
+func (p *WorkerPool) Start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go func(id int) {
+			defer p.wg.Done()
+			log.Debugf("worker %d started", id)
+			for j := range p.jobs {
+				j.result <- p.process(j.parser)
+			}
+			log.Debugf("worker %d stopped", id)
+		}(i)
+	}
+}
+
+// Stop waits for jobs in progress to end, then stops workers
+// Jobs cannot be submitted anymore
+func (p *WorkerPool) Stop() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// Submit a parser to the queue and return a channel to receive the result
+// Blocks until a worker is available or the context is cancelled
+func (p *WorkerPool) Submit(ctx context.Context, parser Parser) (<-chan ParseResult, error) {
+	j := job{parser: parser, result: make(chan ParseResult, 1)}
+	select {
+	case p.jobs <- j:
+		return j.result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// RunAll submits all parsers and waits for their results
+// Results are returned in the same order as parsers
+func (p *WorkerPool) RunAll(parsers []Parser) []ParseResult {
+	channels := make([]chan ParseResult, len(parsers))
+	for i := range parsers {
+		channels[i] = make(chan ParseResult, 1)
+	}
+
+	// feed the queue in background to collect results as soon as they are available
+	go func() {
+		for i, parser := range parsers {
+			p.jobs <- job{parser: parser, result: channels[i]}
+		}
+	}()
+
+	results := make([]ParseResult, len(parsers))
+	for i := range parsers {
+		results[i] = <-channels[i]
+	}
+	return results
+}
+
+// process a single parser with a timeout
+func (p *WorkerPool) process(parser Parser) ParseResult {
+	ctx := context.Background()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	result := ParseResult{Parser: parser.String()}
+	result.ShopName, _ = parser.ShopName()
+
+	start := time.Now()
+	result.Products, result.Err = p.handler(ctx, parser)
+	result.Duration = time.Since(start).Truncate(time.Millisecond)
+	return result
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolRunAll(t *testing.T) {
+	parsers := []Parser{
+		&fakeParser{shopName: "shop1.com", products: []*Product{{Name: "p1"}, {Name: "p2"}}},
+		&fakeParser{shopName: "shop2.com", err: fmt.Errorf("parsing error")},
+		&fakeParser{shopName: "shop3.com", delay: time.Second},
+	}
+
+	handler := func(ctx context.Context, parser Parser) (int, error) {
+		products, err := parser.Parse(ctx)
+		return len(products), err
+	}
+
+	pool := NewWorkerPool(2, 100*time.Millisecond, handler)
+	pool.Start()
+	defer pool.Stop()
+
+	results := pool.RunAll(parsers)
+
+	tests := []struct {
+		shopName string
+		products int
+		failed   bool
+	}{
+		{"shop1.com", 2, false}, // products parsed
+		{"shop2.com", 0, true},  // parser returned an error
+		{"shop3.com", 0, true},  // parser reached the timeout
+	}
+
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(results), len(tests))
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestWorkerPoolRunAll#%d", i), func(t *testing.T) {
+			result := results[i]
+			if result.ShopName != tc.shopName || result.Products != tc.products || (result.Err != nil) != tc.failed {
+				t.Errorf("got %+v, want shop=%s, products=%d, failed=%t", result, tc.shopName, tc.products, tc.failed)
+			} else {
+				t.Logf("got %s", result)
+			}
+		})
+	}
+}
+
+func TestWorkerPoolConcurrency(t *testing.T) {
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+
+	handler := func(ctx context.Context, parser Parser) (int, error) {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return 0, nil
+	}
+
+	var parsers []Parser
+	for i := 0; i < 10; i++ {
+		parsers = append(parsers, &fakeParser{shopName: fmt.Sprintf("shop%d.com", i)})
+	}
+
+	workers := 3
+	pool := NewWorkerPool(workers, 0, handler)
+	pool.Start()
+	pool.RunAll(parsers)
+	pool.Stop()
+
+	if maxRunning != workers {
+		t.Errorf("got %d concurrent jobs, want %d", maxRunning, workers)
+	}
+}
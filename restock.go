@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AvailabilityEvent stores a transition of a product from not available to available, or the opposite
+type AvailabilityEvent struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ProductID     uint      `gorm:"not null;index" json:"product_id"`
+	Product       Product   `gorm:"constraint:OnDelete:CASCADE" json:"product"`
+	ShopID        uint      `gorm:"not null;index" json:"shop_id"`
+	Available     bool      `gorm:"not null" json:"available"`
+	Price         float64   `gorm:"not null" json:"price"`
+	PriceCurrency string    `gorm:"not null" json:"price_currency"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+// NewAvailabilityEvent creates an AvailabilityEvent from the current state of a product
+func NewAvailabilityEvent(p *Product) *AvailabilityEvent {
+	return &AvailabilityEvent{
+		ProductID:     p.ID,
+		ShopID:        p.ShopID,
+		Available:     p.Available,
+		Price:         p.Price,
+		PriceCurrency: p.PriceCurrency,
+	}
+}
+
+// RestockStats stores how often restocks happen and how long they last for a group of products
+// Durations are expressed in seconds
+type RestockStats struct {
+	Name            string    `json:"name"`
+	Restocks        int       `json:"restocks"`
+	Ongoing         int       `json:"ongoing"`
+	MinDuration     int64     `json:"min_duration"`
+	MaxDuration     int64     `json:"max_duration"`
+	AverageDuration int64     `json:"average_duration"`
+	LastRestock     time.Time `json:"last_restock"`
+	totalDuration   int64
+	closedRestocks  int64
+}
+
+// add a restock to the statistics
+// closed is false when the product is still available
+func (s *RestockStats) add(start time.Time, duration time.Duration, closed bool) {
+	s.Restocks++
+	if start.After(s.LastRestock) {
+		s.LastRestock = start
+	}
+	if !closed {
+		s.Ongoing++
+		return
+	}
+	seconds := int64(duration.Seconds())
+	if s.closedRestocks == 0 || seconds < s.MinDuration {
+		s.MinDuration = seconds
+	}
+	if seconds > s.MaxDuration {
+		s.MaxDuration = seconds
+	}
+	s.closedRestocks++
+	s.totalDuration += seconds
+	s.AverageDuration = s.totalDuration / s.closedRestocks
+}
+
+// RestockGroupFunc returns the name of the group of a product, or an empty string to ignore it
+type RestockGroupFunc func(*Product) string
+
+// GroupByShop to compute restock statistics per shop
+func GroupByShop(p *Product) string {
+	return p.Shop.Name
+}
+
+// NewGroupByModel to compute restock statistics per model
+// Models are regexes applied to the product name, the first one to match is the name of the group
+func NewGroupByModel(models []string) (RestockGroupFunc, error) {
+	var regexes []*regexp.Regexp
+	for _, model := range models {
+		re, err := regexp.Compile("(?i)" + model)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile model regex %s: %s", model, err)
+		}
+		regexes = append(regexes, re)
+	}
+	return func(p *Product) string {
+		for i, re := range regexes {
+			if re.MatchString(p.Name) {
+				return models[i]
+			}
+		}
+		return ""
+	}, nil
+}
+
+// ComputeRestockStats pairs availability events of each product to compute restock statistics per group
+// Events must be sorted by creation date and have their Product and Shop loaded
+func ComputeRestockStats(events []AvailabilityEvent, group RestockGroupFunc, now time.Time) []RestockStats {
+	stats := make(map[string]*RestockStats)
+	starts := make(map[uint]time.Time)
+	products := make(map[uint]*Product)
+
+	for i := range events {
+		event := &events[i]
+		products[event.ProductID] = &event.Product
+		if event.Available {
+			if _, ok := starts[event.ProductID]; !ok {
+				starts[event.ProductID] = event.CreatedAt
+			}
+			continue
+		}
+		start, ok := starts[event.ProductID]
+		if !ok {
+			// restock started before the first event
+			continue
+		}
+		delete(starts, event.ProductID)
+		if name := group(&event.Product); name != "" {
+			if _, ok := stats[name]; !ok {
+				stats[name] = &RestockStats{Name: name}
+			}
+			stats[name].add(start, event.CreatedAt.Sub(start), true)
+		}
+	}
+
+	// products still available
+	for productID, start := range starts {
+		if name := group(products[productID]); name != "" {
+			if _, ok := stats[name]; !ok {
+				stats[name] = &RestockStats{Name: name}
+			}
+			stats[name].add(start, now.Sub(start), false)
+		}
+	}
+
+	var results []RestockStats
+	for _, s := range stats {
+		results = append(results, *s)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// FindAvailabilityEvents returns availability events created after a date, sorted by creation date
+func FindAvailabilityEvents(db *gorm.DB, since time.Time) ([]AvailabilityEvent, error) {
+	var events []AvailabilityEvent
+	trx := db.Preload("Product.Shop").Where("created_at >= ?", since).Order("created_at asc, id asc").Find(&events)
+	return events, trx.Error
+}
+
+// PrintRestockReport writes restock statistics per shop and per model
+func PrintRestockReport(w io.Writer, db *gorm.DB, models []string, days int) error {
+	since := time.Now().Add(-time.Hour * 24 * time.Duration(days))
+	events, err := FindAvailabilityEvents(db, since)
+	if err != nil {
+		return err
+	}
+
+	groupByModel, err := NewGroupByModel(models)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	fmt.Fprintf(w, "Restocks for the last %d day(s)\n\n", days)
+	printRestockStats(w, "SHOP", ComputeRestockStats(events, GroupByShop, now))
+	if len(models) > 0 {
+		fmt.Fprintf(w, "\n")
+		printRestockStats(w, "MODEL", ComputeRestockStats(events, groupByModel, now))
+	}
+	return nil
+}
+
+// printRestockStats writes a table of restock statistics
+func printRestockStats(w io.Writer, title string, stats []RestockStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tRESTOCKS\tONGOING\tMIN\tAVG\tMAX\tLAST\n", title)
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", s.Name, s.Restocks, s.Ongoing,
+			time.Duration(s.MinDuration)*time.Second,
+			time.Duration(s.AverageDuration)*time.Second,
+			time.Duration(s.MaxDuration)*time.Second,
+			s.LastRestock.Local().Format("2006-01-02 15:04:05"))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeRestockStats(t *testing.T) {
+	now := time.Date(2021, 5, 22, 12, 0, 0, 0, time.UTC)
+	ldlc := Shop{ID: 1, Name: "ldlc.com"}
+	topachat := Shop{ID: 2, Name: "topachat.com"}
+	p1 := Product{Name: "MSI GeForce RTX 3080 GAMING X", Shop: ldlc}
+	p1.ID = 1
+	p2 := Product{Name: "ASUS GeForce RTX 3070 DUAL", Shop: ldlc}
+	p2.ID = 2
+	p3 := Product{Name: "Palit GeForce RTX 3080 GamingPro", Shop: topachat}
+	p3.ID = 3
+
+	event := func(p Product, available bool, minutesAgo int) AvailabilityEvent {
+		return AvailabilityEvent{ProductID: p.ID, Product: p, ShopID: p.Shop.ID, Available: available, CreatedAt: now.Add(-time.Duration(minutesAgo) * time.Minute)}
+	}
+
+	events := []AvailabilityEvent{
+		event(p1, false, 120), // restock started before the first event, ignored
+		event(p1, true, 100),
+		event(p2, true, 95),
+		event(p1, false, 90), // p1 available for 10 minutes
+		event(p2, false, 35), // p2 available for 60 minutes
+		event(p3, true, 30),
+		event(p1, true, 20),
+		event(p1, false, 18), // p1 available for 2 minutes
+		event(p3, false, 10), // p3 available for 20 minutes
+		event(p2, true, 5),   // p2 still available
+	}
+
+	groupByModel, err := NewGroupByModel([]string{"rtx 3080", "rtx 3090"})
+	if err != nil {
+		t.Fatalf("cannot create model group: %s", err)
+	}
+
+	tests := []struct {
+		group    RestockGroupFunc
+		expected []RestockStats
+	}{
+		{GroupByShop, []RestockStats{
+			{Name: "ldlc.com", Restocks: 4, Ongoing: 1, MinDuration: 120, MaxDuration: 3600, AverageDuration: 1440, LastRestock: now.Add(-5 * time.Minute), totalDuration: 4320, closedRestocks: 3},
+			{Name: "topachat.com", Restocks: 1, Ongoing: 0, MinDuration: 1200, MaxDuration: 1200, AverageDuration: 1200, LastRestock: now.Add(-30 * time.Minute), totalDuration: 1200, closedRestocks: 1},
+		}},
+		{groupByModel, []RestockStats{
+			{Name: "rtx 3080", Restocks: 3, Ongoing: 0, MinDuration: 120, MaxDuration: 1200, AverageDuration: 640, LastRestock: now.Add(-20 * time.Minute), totalDuration: 1920, closedRestocks: 3},
+		}},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestComputeRestockStats#%d", i), func(t *testing.T) {
+			got := ComputeRestockStats(events, tc.group, now)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("got %+v, want %+v", got, tc.expected)
+			} else {
+				t.Logf("got %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/MontFerret/ferret/pkg/compiler"
+	log "github.com/sirupsen/logrus"
+)
+
+// extension of Ferret query files
+const queryFileExtension = ".fql"
+
+// ShopRegistry to store Ferret queries by shop name
+// Built-in queries are registered by default and can be overridden by queries from configuration
+// Registered queries must reference the URL to parse with the @url parameter
+type ShopRegistry struct {
+	queries map[string]string
+}
+
+// NewShopRegistry creates a ShopRegistry with built-in queries
+func NewShopRegistry() *ShopRegistry {
+	return &ShopRegistry{
+		queries: map[string]string{
+			"cybertek.fr":         queryForCybertek,
+			"cdiscount.com":       queryForCdiscount,
+			"ldlc.com":            queryForLDLC,
+			"materiel.net":        queryForMaterielNet,
+			"microcenter.com":     queryForMicroCenter,
+			"mediamarkt.ch":       queryForMediamarktCh,
+			"newegg.com":          queryForNewegg,
+			"steg-electronics.ch": queryForStegElectronics,
+			"topachat.com":        queryForTopachat,
+			"vsgamers.es":         queryForVersusGamers,
+		},
+	}
+}
+
+// NewShopRegistryFromConfig creates a ShopRegistry with built-in queries
+// overridden by queries from a directory then by inline queries
+func NewShopRegistryFromConfig(directory string, queries map[string]string) (*ShopRegistry, error) {
+	registry := NewShopRegistry()
+	if directory != "" {
+		if err := registry.LoadDirectory(directory); err != nil {
+			return nil, err
+		}
+	}
+	for hostname, query := range queries {
+		if err := registry.Register(hostname, query); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Register a query for a hostname, replacing any existing query
+// The query is compiled to detect errors as soon as possible
+func (r *ShopRegistry) Register(hostname string, query string) error {
+	shopName := normalizeHostname(hostname)
+	if _, err := compiler.New().Compile(query); err != nil {
+		return fmt.Errorf("cannot compile query for shop %s: %s", shopName, err)
+	}
+	if _, ok := r.queries[shopName]; ok {
+		log.Debugf("query for shop %s overridden", shopName)
+	}
+	r.queries[shopName] = query
+	return nil
+}
+
+// LoadDirectory registers all queries from files of a directory
+// File names are hostnames with the ".fql" extension (ex: "ldlc.com.fql")
+func (r *ShopRegistry) LoadDirectory(directory string) error {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return fmt.Errorf("cannot read queries directory: %s", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != queryFileExtension {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(directory, file.Name()))
+		if err != nil {
+			return fmt.Errorf("cannot read query file: %s", err)
+		}
+		hostname := strings.TrimSuffix(file.Name(), queryFileExtension)
+		if err = r.Register(hostname, string(content)); err != nil {
+			return err
+		}
+		log.Debugf("query for shop %s loaded from %s", hostname, file.Name())
+	}
+	return nil
+}
+
+// Query returns the query to parse an URL of a shop and the parameters to run it
+func (r *ShopRegistry) Query(shopName string, url string) (string, map[string]interface{}, error) {
+	shopName = normalizeHostname(shopName)
+	query, ok := r.queries[shopName]
+	if !ok {
+		return "", nil, fmt.Errorf("shop %s not supported", shopName)
+	}
+	return query, map[string]interface{}{"url": url}, nil
+}
+
+// Supports returns true when a query is registered for the shop
+func (r *ShopRegistry) Supports(shopName string) bool {
+	_, ok := r.queries[normalizeHostname(shopName)]
+	return ok
+}
+
+// normalizeHostname removes leading www to match shop names
+func normalizeHostname(hostname string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(hostname)), "www.")
+}
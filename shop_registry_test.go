@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestShopRegistryQuery(t *testing.T) {
+	customQuery := `RETURN [{name: "custom", url: @url, price: 1, price_currency: "EUR", available: true}]`
+
+	directory := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(directory, "topachat.com.fql"), []byte(customQuery), 0644); err != nil {
+		t.Fatalf("cannot write query file: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(directory, "README.md"), []byte("not a query"), 0644); err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+
+	registry, err := NewShopRegistryFromConfig(directory, map[string]string{"www.newshop.com": customQuery})
+	if err != nil {
+		t.Fatalf("cannot create registry: %s", err)
+	}
+
+	tests := []struct {
+		shopName  string
+		custom    bool // query is not a built-in one
+		supported bool
+	}{
+		{"ldlc.com", false, true},     // built-in query
+		{"topachat.com", true, true},  // built-in query overridden by a file
+		{"newshop.com", true, true},   // query from configuration
+		{"unknown.com", false, false}, // unsupported shop
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestShopRegistryQuery#%d", i), func(t *testing.T) {
+			url := "https://www." + tc.shopName + "/products"
+			query, params, err := registry.Query(tc.shopName, url)
+			if registry.Supports(tc.shopName) != tc.supported {
+				t.Errorf("for %s, got supported=%t, want supported=%t", tc.shopName, !tc.supported, tc.supported)
+			}
+			if !tc.supported {
+				if err == nil {
+					t.Errorf("for %s, got no error, want an error", tc.shopName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("for %s, got error %s", tc.shopName, err)
+			}
+			if tc.custom && (query != customQuery || params["url"] != url) {
+				t.Errorf("for %s, got query '%s' with params %+v, want custom query with url %s", tc.shopName, query, params, url)
+			} else {
+				t.Logf("for %s, got query with params %+v", tc.shopName, params)
+			}
+		})
+	}
+}
+
+func TestShopRegistryRegisterInvalidQuery(t *testing.T) {
+	registry := NewShopRegistry()
+	if err := registry.Register("ldlc.com", "RETURN ("); err == nil {
+		t.Errorf("got no error for invalid query, want an error")
+	}
+}
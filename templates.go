@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// Languages of built-in message templates
+const (
+	LanguageEnglish = "en"
+	LanguageFrench  = "fr"
+)
+
+// MessageData to render message templates
+type MessageData struct {
+	Shop              string
+	Name              string
+	Price             float64
+	Currency          string
+	FormattedPrice    string
+	OldPrice          float64
+	FormattedOldPrice string
+	URL               string
+	Duration          time.Duration
+	Hashtags          string
+	Counter           int64
+	Date              string
+}
+
+// NewMessageData creates MessageData from an event
+func NewMessageData(event *RestockEvent) *MessageData {
+	product := event.Product
+	data := &MessageData{
+		Shop:           product.Shop.Name,
+		Name:           product.Name,
+		Price:          product.Price,
+		Currency:       product.PriceCurrency,
+		FormattedPrice: formatPrice(product.Price, product.PriceCurrency),
+		URL:            product.URL,
+		Duration:       event.Duration,
+		Date:           event.CreatedAt.UTC().Format("2006-01-02 15:04:05 (-0700)"),
+	}
+	if event.Previous != nil {
+		data.OldPrice = event.Previous.Price
+		data.FormattedOldPrice = formatPrice(event.Previous.Price, event.Previous.PriceCurrency)
+	}
+	return data
+}
+
+// messageTemplateTexts stores built-in templates of a language
+type messageTemplateTexts struct {
+	available    string
+	notAvailable string
+	priceDrop    string
+	subject      string
+}
+
+// MessageTemplates to render messages of a notifier
+type MessageTemplates struct {
+	available    *template.Template
+	notAvailable *template.Template
+	priceDrop    *template.Template
+	subject      *template.Template
+}
+
+// NewMessageTemplates creates MessageTemplates from configuration
+// Built-in templates of the configured language are used when templates are not defined
+func NewMessageTemplates(config TemplatesConfig, defaults map[string]messageTemplateTexts) (*MessageTemplates, error) {
+	language := config.Language
+	if language == "" {
+		language = LanguageEnglish
+	}
+	texts, ok := defaults[language]
+	if !ok {
+		return nil, fmt.Errorf("language %s not supported", language)
+	}
+	if config.Available != "" {
+		texts.available = config.Available
+	}
+	if config.NotAvailable != "" {
+		texts.notAvailable = config.NotAvailable
+	}
+	if config.PriceDrop != "" {
+		texts.priceDrop = config.PriceDrop
+	}
+	if config.Subject != "" {
+		texts.subject = config.Subject
+	}
+
+	available, err := template.New("available").Parse(texts.available)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse available template: %s", err)
+	}
+	notAvailable, err := template.New("not_available").Parse(texts.notAvailable)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse not available template: %s", err)
+	}
+	priceDrop, err := template.New("price_drop").Parse(texts.priceDrop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse price drop template: %s", err)
+	}
+	subject, err := template.New("subject").Parse(texts.subject)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse subject template: %s", err)
+	}
+	return &MessageTemplates{available: available, notAvailable: notAvailable, priceDrop: priceDrop, subject: subject}, nil
+}
+
+// mustMessageTemplates creates MessageTemplates from built-in templates only
+func mustMessageTemplates(defaults map[string]messageTemplateTexts) *MessageTemplates {
+	templates, err := NewMessageTemplates(TemplatesConfig{}, defaults)
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+// Available renders the message sent when a product is available
+func (t *MessageTemplates) Available(data *MessageData) (string, error) {
+	return render(t.available, data)
+}
+
+// AvailableWithMaxSize renders the message sent when a product is available
+// Product name is truncated when the message is bigger than maxSize characters, then the whole message
+// when it's still too big (ex: custom template without or with many product names)
+func (t *MessageTemplates) AvailableWithMaxSize(data *MessageData, maxSize int) (string, error) {
+	message, err := t.Available(data)
+	if err != nil {
+		return "", err
+	}
+	size := utf8.RuneCountInString(message)
+	if size <= maxSize {
+		return message, nil
+	}
+
+	// remove extra characters from the product name, plus one for the "…" to say it has been truncated
+	name := []rune(data.Name)
+	keep := len(name) - (size - maxSize) - 1
+	if keep < 0 {
+		keep = 0
+	}
+	truncated := *data
+	truncated.Name = string(name[:keep]) + "…"
+	message, err = t.Available(&truncated)
+	if err != nil {
+		return "", err
+	}
+	return truncate(message, maxSize), nil
+}
+
+// truncate a message to maxSize characters, ending with "…" when truncated
+func truncate(message string, maxSize int) string {
+	runes := []rune(message)
+	if len(runes) <= maxSize {
+		return message
+	}
+	if maxSize < 1 {
+		return ""
+	}
+	return string(runes[:maxSize-1]) + "…"
+}
+
+// NotAvailable renders the message sent when a product is not available anymore
+func (t *MessageTemplates) NotAvailable(data *MessageData) (string, error) {
+	return render(t.notAvailable, data)
+}
+
+// PriceDrop renders the message sent when the price of an available product has decreased
+func (t *MessageTemplates) PriceDrop(data *MessageData) (string, error) {
+	return render(t.priceDrop, data)
+}
+
+// Subject renders the subject of the email sent when a product is available
+func (t *MessageTemplates) Subject(data *MessageData) (string, error) {
+	return render(t.subject, data)
+}
+
+// render a template to string
+func render(tmpl *template.Template, data *MessageData) (string, error) {
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("cannot render %s template: %s", tmpl.Name(), err)
+	}
+	return buffer.String(), nil
+}
+
+// textToHTML converts a plain text message to HTML with line breaks and a link to the product
+func textToHTML(text string, productURL string) string {
+	escaped := strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+	if productURL != "" {
+		escapedURL := html.EscapeString(productURL)
+		escaped = strings.ReplaceAll(escaped, escapedURL, fmt.Sprintf(`<a href="%s">%s</a>`, escapedURL, escapedURL))
+	}
+	return escaped
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestNewMessageTemplates(t *testing.T) {
+	product := Product{Name: "my awesome product", URL: "https://shop.com/awesome", Price: 999.99, PriceCurrency: "EUR"}
+	data := NewMessageData(NewRestockEvent(EventAvailable, Shop{Name: "shop.com"}, product, nil, 90*time.Second))
+
+	tests := []struct {
+		config       TemplatesConfig
+		available    string
+		notAvailable string
+		err          bool
+	}{
+		{TemplatesConfig{}, "shop.com: my awesome product for 999.99€ is available at https://shop.com/awesome ", "And it's gone (1m30s)", false},
+		{TemplatesConfig{Language: "fr"}, "shop.com : my awesome product à 999.99€ est disponible sur https://shop.com/awesome ", "Et c'est fini (1m30s)", false},
+		{TemplatesConfig{Language: "fr", NotAvailable: "Victime de son succès après {{.Duration}}"}, "shop.com : my awesome product à 999.99€ est disponible sur https://shop.com/awesome ", "Victime de son succès après 1m30s", false},
+		{TemplatesConfig{Available: "{{.Name}} ({{printf \"%.0f\" .Price}} {{.Currency}})"}, "my awesome product (1000 EUR)", "And it's gone (1m30s)", false},
+		{TemplatesConfig{Language: "de"}, "", "", true},             // language not supported
+		{TemplatesConfig{Available: "{{.Name"}, "", "", true},       // invalid template
+		{TemplatesConfig{Available: "{{.Unknown}}"}, "", "", false}, // error on render
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestNewMessageTemplates#%d", i), func(t *testing.T) {
+			templates, err := NewMessageTemplates(tc.config, statusTemplates)
+			if tc.err {
+				if err == nil {
+					t.Errorf("for %+v, got no error, want an error", tc.config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("for %+v, got error %s", tc.config, err)
+			}
+
+			available, err := templates.Available(data)
+			if tc.available == "" {
+				if err == nil {
+					t.Errorf("for %+v, got '%s', want an error", tc.config, available)
+				}
+				return
+			}
+			notAvailable, _ := templates.NotAvailable(data)
+			if available != tc.available || notAvailable != tc.notAvailable {
+				t.Errorf("for %+v, got '%s' and '%s', want '%s' and '%s'", tc.config, available, notAvailable, tc.available, tc.notAvailable)
+			} else {
+				t.Logf("for %+v, got '%s' and '%s'", tc.config, available, notAvailable)
+			}
+		})
+	}
+}
+
+func TestAvailableWithMaxSize(t *testing.T) {
+	product := Product{Name: "my awesome product", URL: "https://shop.com/awesome", Price: 999.99, PriceCurrency: "USD"}
+	data := NewMessageData(NewRestockEvent(EventAvailable, Shop{Name: "shop.com"}, product, nil, 0))
+	data.Hashtags = "#awesome"
+
+	tests := []struct {
+		available string // built-in template when empty
+		maxSize   int
+		expected  string
+	}{
+		{"", 500, "shop.com: my awesome product for $999.99 is available at https://shop.com/awesome #awesome"},
+		{"", 80, "shop.com: my awes… for $999.99 is available at https://shop.com/awesome #awesome"},
+		{"", 10, "shop.com:…"}, // product name removed then message truncated
+		{"{{.Name}} at {{.URL}}", 30, "m… at https://shop.com/awesome"},
+		{"{{.URL}} {{.URL}}", 30, "https://shop.com/awesome http…"}, // without product name
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestAvailableWithMaxSize#%d", i), func(t *testing.T) {
+			templates, err := NewMessageTemplates(TemplatesConfig{Available: tc.available}, statusTemplates)
+			if err != nil {
+				t.Fatalf("for template '%s', got error %s", tc.available, err)
+			}
+			got, err := templates.AvailableWithMaxSize(data, tc.maxSize)
+			if err != nil {
+				t.Fatalf("for max size %d, got error %s", tc.maxSize, err)
+			}
+			if got != tc.expected || utf8.RuneCountInString(got) > tc.maxSize {
+				t.Errorf("for max size %d, got '%s', want '%s'", tc.maxSize, got, tc.expected)
+			} else {
+				t.Logf("for max size %d, got '%s'", tc.maxSize, got)
+			}
+		})
+	}
+}
+
+func TestNewMessageData(t *testing.T) {
+	_, product := newNotifierTestDatabase(t)
+
+	tests := []struct {
+		event    *RestockEvent
+		duration time.Duration
+	}{
+		{newAvailableEvent(product), 0},
+		{newNotAvailableEvent(product, time.Minute), time.Minute},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestNewMessageData#%d", i), func(t *testing.T) {
+			got := NewMessageData(tc.event)
+			if got.Shop != "ldlc.com" || got.Name != product.Name || got.FormattedPrice != "899.99€" || got.URL != product.URL || got.Duration != tc.duration || got.Date == "" {
+				t.Errorf("got %+v, want product %s of ldlc.com at 899.99€ after %s", got, product.Name, tc.duration)
+			} else {
+				t.Logf("got %+v", got)
+			}
+		})
+	}
+}
+
+func TestTextToHTML(t *testing.T) {
+	tests := []struct {
+		text     string
+		url      string
+		expected string
+	}{
+		{"Name: <b>product</b>\nURL: https://shop.com/?a=1&b=2", "https://shop.com/?a=1&b=2", `Name: &lt;b&gt;product&lt;/b&gt;<br>URL: <a href="https://shop.com/?a=1&amp;b=2">https://shop.com/?a=1&amp;b=2</a>`},
+		{"And it's gone (1m30s)", "https://shop.com/", "And it&#39;s gone (1m30s)"},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestTextToHTML#%d", i), func(t *testing.T) {
+			got := textToHTML(tc.text, tc.url)
+			if got != tc.expected {
+				t.Errorf("for '%s', got '%s', want '%s'", tc.text, got, tc.expected)
+			} else {
+				t.Logf("for '%s', got '%s'", tc.text, got)
+			}
+		})
+	}
+}
+
+func TestSubject(t *testing.T) {
+	product := Product{Name: "my awesome product", URL: "https://shop.com/awesome", Price: 999.99, PriceCurrency: "EUR"}
+	data := NewMessageData(NewRestockEvent(EventAvailable, Shop{Name: "shop.com"}, product, nil, 0))
+
+	tests := []struct {
+		config   TemplatesConfig
+		expected string
+	}{
+		{TemplatesConfig{}, "my awesome product is available at shop.com for 999.99€"},
+		{TemplatesConfig{Language: "fr"}, "my awesome product est disponible chez shop.com à 999.99€"},
+		{TemplatesConfig{Subject: "[restock] {{.Name}}"}, "[restock] my awesome product"},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestSubject#%d", i), func(t *testing.T) {
+			templates, err := NewMessageTemplates(tc.config, textTemplates)
+			if err != nil {
+				t.Fatalf("for %+v, got error %s", tc.config, err)
+			}
+			got, err := templates.Subject(data)
+			if err != nil || got != tc.expected {
+				t.Errorf("for %+v, got '%s' (error %v), want '%s'", tc.config, got, err, tc.expected)
+			} else {
+				t.Logf("for %+v, got '%s'", tc.config, got)
+			}
+		})
+	}
+}
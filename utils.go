@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -36,3 +38,30 @@ func CoalesceInt64(values ...int64) int64 {
 	}
 	return 0
 }
+
+// ParsePrice converts a price formatted by a website to a float
+// Currency symbols and spaces are removed. The last separator is the decimal separator
+// when followed by one or two digits ("1 299,99€" -> 1299.99, "$1,299.99" -> 1299.99, "1.299" -> 1299)
+// A currency symbol between digits is a decimal separator ("899€99" -> 899.99)
+func ParsePrice(value string) (float64, error) {
+	symbol := regexp.MustCompile(`([0-9])[^0-9.,'\s]+([0-9])`)
+	cleaned := symbol.ReplaceAllString(value, "$1.$2")
+
+	re := regexp.MustCompile(`[^0-9.,]`)
+	cleaned = re.ReplaceAllString(cleaned, "")
+	if cleaned == "" {
+		return 0.0, fmt.Errorf("no price found in '%s'", value)
+	}
+
+	decimals := ""
+	if i := strings.LastIndexAny(cleaned, ".,"); i != -1 && len(cleaned)-i-1 <= 2 {
+		decimals = cleaned[i+1:]
+		cleaned = cleaned[:i]
+	}
+	cleaned = strings.NewReplacer(".", "", ",", "").Replace(cleaned)
+	if decimals != "" {
+		cleaned = cleaned + "." + decimals
+	}
+
+	return strconv.ParseFloat(cleaned, 64)
+}
@@ -59,3 +59,38 @@ func TestCoalesceInt64(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+	}{
+		{"899.99", 899.99},
+		{"899,99 €", 899.99},
+		{"1 299,99€", 1299.99},
+		{"$1,299.99", 1299.99},
+		{"1.299,9", 1299.9},
+		{"1.299", 1299},
+		{"CHF 1'299.-", 1299},
+		{"749", 749},
+		{"899€99", 899.99},
+		{"1 049€95", 1049.95},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("TestParsePrice#%d", i), func(t *testing.T) {
+			got, err := ParsePrice(tc.value)
+			if err != nil {
+				t.Errorf("for '%s': got error %s, want %.2f", tc.value, err, tc.expected)
+			} else if got != tc.expected {
+				t.Errorf("for '%s': got %.2f, want %.2f", tc.value, got, tc.expected)
+			} else {
+				t.Logf("for '%s': got %.2f, want %.2f", tc.value, got, tc.expected)
+			}
+		})
+	}
+
+	if _, err := ParsePrice("out of stock"); err == nil {
+		t.Errorf("for 'out of stock': got no error, want an error")
+	}
+}